@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"code.dogecoin.org/identity/internal/profile"
+	"code.dogecoin.org/identity/internal/sign"
+)
+
+// Subcommands run instead of the service: identity <command> [flags]
+
+const commandUsage = `usage: identity [flags]                  run the service (see -h)
+       identity keygen                   generate an identity private key (for KEY)
+       identity pubkey                   show the identity pubkey for KEY (or --key-file)
+       identity show <pubkey>            show a stored identity
+       identity list                     list stored identities
+       identity search <name>            find stored identities by name
+       identity trim                     expire old records now
+       identity export [--out <file>]    export stored identities (JSON Lines)
+       identity import [file]            import identities from export
+       identity profile get|set [flags]  show or edit our profile
+       identity verify [flags]           verify a signed message (see /sign)
+
+Commands take --dir <path> (default './storage') and -h for their flags.
+`
+
+// commands maps each subcommand to its function, which returns the
+// process exit code. Other arguments run the service as before.
+var commands = map[string]func(args []string) int{
+	"keygen":  cmdKeygen,
+	"pubkey":  cmdPubkey,
+	"show":    cmdShow,
+	"list":    cmdList,
+	"search":  cmdSearch,
+	"trim":    cmdTrim,
+	"export":  cmdExport,
+	"import":  cmdImport,
+	"profile": cmdProfile,
+	"verify":  cmdVerify,
+	"help":    cmdHelp,
+}
+
+func cmdHelp(args []string) int {
+	fmt.Print(commandUsage)
+	return 0
+}
+
+// cmdVerify checks a message signed with POST /sign; exits 0 if valid, 1 if not.
+func cmdVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	identity := fs.String("identity", "", "<hex> - identity pubkey of the signer")
+	domain := fs.String("domain", "", "app or site the message was signed for")
+	signature := fs.String("signature", "", "<hex> - 64-byte signature")
+	message := fs.String("message", "", "signed message (default: read from stdin)")
+	dir := fs.String("dir", "", "<path> - storage directory: also show the signer's name if stored")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	idenPub, err := hex.DecodeString(*identity)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "--identity: expecting hex: %v\n", err)
+		return 2
+	}
+	sig, err := hex.DecodeString(*signature)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "--signature: expecting hex: %v\n", err)
+		return 2
+	}
+	msg := *message
+	if !isFlagSet(fs, "message") {
+		text, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot read message: %v\n", err)
+			return 2
+		}
+		msg = string(text)
+	}
+	valid, err := sign.Verify(idenPub, *domain, msg, sig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	signer := hex.EncodeToString(idenPub)
+	if *dir != "" {
+		if name := storedName(*dir, idenPub); name != "" {
+			signer = fmt.Sprintf("%v (%v)", signer, name)
+		}
+	}
+	if !valid {
+		fmt.Printf("INVALID signature by %v\n", signer)
+		return 1
+	}
+	fmt.Printf("valid signature by %v\n", signer)
+	return 0
+}
+
+// storedName returns the announced name of a stored identity ("" if none)
+func storedName(dir string, idenPub []byte) string {
+	db, err := openStore(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ""
+	}
+	payload, _, _, err := db.GetIdentity(idenPub)
+	if err != nil {
+		return ""
+	}
+	msg, _ := profile.DecodeIdentity(payload)
+	return msg.Name
+}
+
+func isFlagSet(fs *flag.FlagSet, name string) (set bool) {
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return
+}
@@ -0,0 +1,592 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register JPEG for image.Decode
+	_ "image/png"  // register PNG for image.Decode
+	"io"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"code.dogecoin.org/gossip/dnet"
+	"code.dogecoin.org/identity/internal/config"
+	"code.dogecoin.org/identity/internal/dogeicon"
+	"code.dogecoin.org/identity/internal/policy"
+	"code.dogecoin.org/identity/internal/profile"
+	"code.dogecoin.org/identity/internal/spec"
+	"code.dogecoin.org/identity/internal/store"
+	"code.dogecoin.org/identity/internal/web"
+	"github.com/dogeorg/doge"
+)
+
+// Subcommands that work directly on identity.db. They are safe to run
+// while the service is running: SQLite serializes writers, and the store
+// retries transactions while the database is locked.
+
+const ListPageSize = 1000
+
+// ExportedIdentity is one line of `identity export` (JSON Lines): the
+// signed identity message exactly as gossiped, so `identity import` can
+// check the signature.
+type ExportedIdentity struct {
+	Identity  string `json:"identity"`  // identity pubkey (hex)
+	Payload   string `json:"payload"`   // encoded IdentityMsg (hex)
+	Signature string `json:"signature"` // signature of the payload (hex)
+}
+
+// openStore opens the database in the storage directory (which must exist:
+// commands never create a new database)
+func openStore(dir string) (spec.StoreCtx, error) {
+	filename := path.Join(dir, DBFileName)
+	if _, err := os.Stat(filename); err != nil {
+		return nil, fmt.Errorf("cannot open database: %v", err)
+	}
+	db, err := store.New(filename, context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("cannot open database: %v [%v]", err, filename)
+	}
+	return db.WithCtx(context.Background()), nil
+}
+
+// parseCommand parses flags before and after positional arguments.
+func parseCommand(fs *flag.FlagSet, args []string) (positional []string, ok bool) {
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, false
+		}
+		if fs.NArg() == 0 {
+			return positional, true
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func dirFlag(fs *flag.FlagSet) *string {
+	return fs.String("dir", "./storage", "<path> - storage directory")
+}
+
+// cmdKeygen generates a new identity private key for the KEY env-var.
+func cmdKeygen(args []string) int {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	if _, ok := parseCommand(fs, args); !ok {
+		return 2
+	}
+	key, err := dnet.GenerateKeyPair()
+	if err != nil || key.Priv == nil {
+		fmt.Fprintf(os.Stderr, "cannot generate key: %v\n", err)
+		return 1
+	}
+	// the private key goes to stdout, e.g. KEY=$(identity keygen)
+	fmt.Println(hex.EncodeToString(key.Priv[:]))
+	fmt.Fprintf(os.Stderr, "identity pubkey: %v\n", hex.EncodeToString(key.Pub[:]))
+	return 0
+}
+
+// cmdPubkey prints the identity pubkey for the KEY env-var
+// (or the key source in the config file)
+func cmdPubkey(args []string) int {
+	fs := flag.NewFlagSet("pubkey", flag.ContinueOnError)
+	dir := dirFlag(fs)
+	var flags config.File
+	fs.StringVar(&flags.KeyFile, "key-file", "", "<path> - read the private key from a file instead of the KEY env-var")
+	if _, ok := parseCommand(fs, args); !ok {
+		return 2
+	}
+	conf, err := loadConfig(path.Join(*dir, config.FileName), false, flags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	key := loadKey(conf)
+	fmt.Println(hex.EncodeToString(key.Pub[:]))
+	return 0
+}
+
+// cmdShow prints a stored identity: show <pubkey>
+func cmdShow(args []string) int {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	dir := dirFlag(fs)
+	pos, ok := parseCommand(fs, args)
+	if !ok {
+		return 2
+	}
+	if len(pos) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: identity show [--dir <path>] <pubkey>")
+		return 2
+	}
+	idenPub, err := hex.DecodeString(pos[0])
+	if err != nil || len(idenPub) != 32 {
+		fmt.Fprintf(os.Stderr, "invalid pubkey: expecting 32 bytes hex (got %q)\n", pos[0])
+		return 2
+	}
+	db, err := openStore(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	payload, _, signed, err := db.GetIdentity(idenPub)
+	if err != nil {
+		if spec.IsNotFoundError(err) {
+			fmt.Fprintln(os.Stderr, "identity not found")
+		} else {
+			fmt.Fprintf(os.Stderr, "cannot load identity: %v\n", err)
+		}
+		return 1
+	}
+	msg, ok := profile.DecodeIdentity(payload)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "stored identity is malformed")
+		return 1
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "identity\t%v\n", hex.EncodeToString(idenPub))
+	fmt.Fprintf(tw, "name\t%v\n", msg.Name)
+	fmt.Fprintf(tw, "bio\t%v\n", msg.Bio)
+	fmt.Fprintf(tw, "location\t%v, %v\n", float64(msg.Lat)/10.0, float64(msg.Long)/10.0) // undo quantization
+	fmt.Fprintf(tw, "city\t%v\n", msg.City)
+	fmt.Fprintf(tw, "country\t%v\n", strings.TrimRight(msg.Country, "\x00"))
+	for _, node := range msg.Nodes {
+		fmt.Fprintf(tw, "node\t%v\n", hex.EncodeToString(node))
+	}
+	fmt.Fprintf(tw, "icon\t%v bytes\n", len(msg.Icon))
+	fmt.Fprintf(tw, "signed\t%v\n", formatTime(signed))
+	expires, err := db.GetIdentityExpiry(idenPub)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot load identity expiry: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(tw, "expires\t%v\n", formatTime(expires))
+	contacts, err := db.ListContacts()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot list contacts: %v\n", err)
+		return 1
+	}
+	for _, c := range contacts {
+		if string(c.PubKey) == string(idenPub) {
+			fmt.Fprintf(tw, "contact\t%v (pinned %v)\n", c.Name, formatTime(c.Time))
+		}
+	}
+	if p, err := db.GetPetname(idenPub); err == nil {
+		fmt.Fprintf(tw, "petname\t%v\n", p.Petname)
+		if p.Note != "" {
+			fmt.Fprintf(tw, "note\t%v\n", p.Note)
+		}
+	}
+	if found, err := db.FindLookalike(idenPub); err == nil && found != nil {
+		kind := "an older identity"
+		if found.Pinned {
+			kind = "pinned contact"
+		}
+		fmt.Fprintf(tw, "lookalike\tname looks like %v %q (%v)\n", kind, found.Name, hex.EncodeToString(found.PubKey))
+	}
+	tw.Flush()
+	return 0
+}
+
+// cmdList lists stored identities in pubkey order.
+func cmdList(args []string) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	dir := dirFlag(fs)
+	limit := fs.Int("limit", 0, "maximum number of identities to list (0 for all)")
+	if _, ok := parseCommand(fs, args); !ok {
+		return 2
+	}
+	db, err := openStore(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	tw := newIdentityTable()
+	var after []byte
+	count := 0
+	for *limit == 0 || count < *limit {
+		page := ListPageSize
+		if *limit != 0 && *limit-count < page {
+			page = *limit - count
+		}
+		list, err := db.ListIdentities(after, page)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot list identities: %v\n", err)
+			return 1
+		}
+		for _, id := range list {
+			printIdentityRow(tw, id)
+		}
+		count += len(list)
+		if len(list) < page {
+			break
+		}
+		after = list[len(list)-1].PubKey
+	}
+	tw.Flush()
+	return 0
+}
+
+// cmdSearch finds stored identities by name: search <text>
+func cmdSearch(args []string) int {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	dir := dirFlag(fs)
+	limit := fs.Int("limit", 50, "maximum number of identities to list")
+	pos, ok := parseCommand(fs, args)
+	if !ok {
+		return 2
+	}
+	if len(pos) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: identity search [--dir <path>] [--limit <n>] <name>")
+		return 2
+	}
+	db, err := openStore(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	list, err := db.FindIdentitiesByName(strings.Join(pos, " "), *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot search identities: %v\n", err)
+		return 1
+	}
+	if len(list) == 0 {
+		fmt.Fprintln(os.Stderr, "no identities found")
+		return 1
+	}
+	tw := newIdentityTable()
+	for _, id := range list {
+		printIdentityRow(tw, id)
+	}
+	tw.Flush()
+	return 0
+}
+
+// cmdTrim expires old records now (the service trims while connected to dogenet)
+func cmdTrim(args []string) int {
+	fs := flag.NewFlagSet("trim", flag.ContinueOnError)
+	dir := dirFlag(fs)
+	if _, ok := parseCommand(fs, args); !ok {
+		return 2
+	}
+	db, err := openStore(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	before, err := db.CountIdentities()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot count identities: %v\n", err)
+		return 1
+	}
+	advanced, err := db.Trim()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot trim: %v\n", err)
+		return 1
+	}
+	if !advanced {
+		fmt.Println("already trimmed today")
+		return 0
+	}
+	after, err := db.CountIdentities()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot count identities: %v\n", err)
+		return 1
+	}
+	fmt.Printf("expired %v identities (%v remain)\n", before-after, after)
+	return 0
+}
+
+// cmdExport writes all stored identities as JSON Lines.
+func cmdExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	dir := dirFlag(fs)
+	out := fs.String("out", "", "<path> - output file (default: stdout)")
+	if _, ok := parseCommand(fs, args); !ok {
+		return 2
+	}
+	db, err := openStore(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot create output file: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	var after []byte
+	count := 0
+	for {
+		list, err := db.ListIdentities(after, ListPageSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot list identities: %v\n", err)
+			return 1
+		}
+		for _, id := range list {
+			err = enc.Encode(ExportedIdentity{
+				Identity:  hex.EncodeToString(id.PubKey),
+				Payload:   hex.EncodeToString(id.Payload),
+				Signature: hex.EncodeToString(id.Sig),
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "cannot write export: %v\n", err)
+				return 1
+			}
+		}
+		count += len(list)
+		if len(list) < ListPageSize {
+			break
+		}
+		after = list[len(list)-1].PubKey
+	}
+	if err := buf.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot write export: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "exported %v identities\n", count)
+	return 0
+}
+
+// cmdImport stores identities from `identity export`, checking each one
+// as if it had been received from the network: import [file]
+func cmdImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	dir := dirFlag(fs)
+	pos, ok := parseCommand(fs, args)
+	if !ok {
+		return 2
+	}
+	if len(pos) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: identity import [--dir <path>] [file]")
+		return 2
+	}
+	db, err := openStore(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var r io.Reader = os.Stdin
+	if len(pos) == 1 && pos[0] != "-" {
+		file, err := os.Open(pos[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot open import file: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		r = file
+	}
+	rules, err := db.ListPolicyRules()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot load policy: %v\n", err)
+		return 1
+	}
+	blocks := policy.New()
+	blocks.Load(rules)
+
+	imported, rejected := 0, 0
+	scan := bufio.NewScanner(r)
+	line := 0
+	for scan.Scan() {
+		line++
+		if strings.TrimSpace(scan.Text()) == "" {
+			continue
+		}
+		id, err := checkImported(scan.Bytes(), blocks)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "line %v: %v\n", line, err)
+			rejected++
+			continue
+		}
+		err = db.SetIdentity(id.PubKey, id.Payload, id.Sig, id.Time)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot store identity: %v\n", err)
+			return 1
+		}
+		imported++
+	}
+	if err := scan.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot read import file: %v\n", err)
+		return 1
+	}
+	fmt.Printf("imported %v identities (%v rejected)\n", imported, rejected)
+	return 0
+}
+
+// checkImported decodes and checks one exported identity.
+func checkImported(line []byte, blocks *policy.Policy) (spec.Identity, error) {
+	var ex ExportedIdentity
+	if err := json.Unmarshal(line, &ex); err != nil {
+		return spec.Identity{}, fmt.Errorf("error decoding JSON: %v", err)
+	}
+	pub, err1 := hex.DecodeString(ex.Identity)
+	payload, err2 := hex.DecodeString(ex.Payload)
+	sig, err3 := hex.DecodeString(ex.Signature)
+	if err1 != nil || err2 != nil || err3 != nil || len(pub) != 32 || len(sig) != 64 {
+		return spec.Identity{}, fmt.Errorf("invalid identity: expecting hex identity (32 bytes), payload and signature (64 bytes)")
+	}
+	if !doge.VerifyMessage((*[32]byte)(pub), payload, (*[64]byte)(sig)) {
+		return spec.Identity{}, fmt.Errorf("invalid signature: %v", ex.Identity)
+	}
+	msg, ok := profile.DecodeReceived(payload)
+	if !ok {
+		return spec.Identity{}, fmt.Errorf("invalid identity: malformed payload: %v", ex.Identity)
+	}
+	if err := profile.CheckIdentity(&msg); err != nil {
+		return spec.Identity{}, fmt.Errorf("rejected identity %v: %v", ex.Identity, err)
+	}
+	signed := msg.Time.Local().Unix()
+	if time.Since(time.Unix(signed, 0)) > spec.ExpiryTime {
+		return spec.Identity{}, fmt.Errorf("expired identity: %v (signed %v)", ex.Identity, formatTime(signed))
+	}
+	if why := blocks.Blocked(pub, msg.Name, msg.Nodes); why != "" {
+		return spec.Identity{}, fmt.Errorf("blocked identity: %v: %v", ex.Identity, why)
+	}
+	return spec.Identity{PubKey: pub, Payload: payload, Sig: sig, Time: signed}, nil
+}
+
+// cmdProfile shows or edits our profile: profile get | profile set [flags]
+func cmdProfile(args []string) int {
+	usage := "usage: identity profile get [--dir <path>]\n       identity profile set [--dir <path>] [--name ..] [--bio ..] [--lat ..] [--lon ..] [--country ..] [--city ..] [--icon <image>]"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	fs := flag.NewFlagSet("profile "+args[0], flag.ContinueOnError)
+	dir := dirFlag(fs)
+	var name, bio, country, city, icon *string
+	var lat, lon *float64
+	switch args[0] {
+	case "get":
+	case "set":
+		name = fs.String("name", "", "display name")
+		bio = fs.String("bio", "", "short biography")
+		lat = fs.Float64("lat", 0, "latitude (WGS84 degrees)")
+		lon = fs.Float64("lon", 0, "longitude (WGS84 degrees)")
+		country = fs.String("country", "", "ISO 3166-1 alpha-2 country code")
+		city = fs.String("city", "", "city name")
+		icon = fs.String("icon", "", "<path> - PNG or JPEG image for the icon")
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	pos, ok := parseCommand(fs, args[1:])
+	if !ok {
+		return 2
+	}
+	if len(pos) != 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	db, err := openStore(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	pro, err := db.GetProfile()
+	if err != nil && !spec.IsNotFoundError(err) {
+		fmt.Fprintf(os.Stderr, "cannot load profile: %v\n", err)
+		return 1
+	}
+	if args[0] == "set" {
+		// flags that are not set keep their current values
+		to := web.NewIdentFromProfile(&pro)
+		to.Long = 0 // deprecated
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "name":
+				to.Name = *name
+			case "bio":
+				to.Bio = *bio
+			case "lat":
+				to.Lat = *lat
+			case "lon":
+				to.Lon = *lon
+			case "country":
+				to.Country = *country
+			case "city":
+				to.City = *city
+			}
+		})
+		newPro, err := web.ValidateProfile(to)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if *icon != "" {
+			newPro.Icon, err = encodeIconFile(*icon)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+		}
+		err = db.SetProfile(newPro)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot store profile: %v\n", err)
+			return 1
+		}
+		pro = newPro
+		fmt.Fprintln(os.Stderr, "profile saved: a running service announces it within a minute")
+	}
+	bytes, err := json.MarshalIndent(web.NewIdentFromProfile(&pro), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error encoding JSON: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(bytes))
+	return 0
+}
+
+func encodeIconFile(filename string) ([]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open icon: %v", err)
+	}
+	defer file.Close()
+	conf, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid icon: expecting PNG or JPEG: %v", err)
+	}
+	if conf.Width > web.MaxIconUploadDim || conf.Height > web.MaxIconUploadDim {
+		return nil, fmt.Errorf("invalid icon: larger than %vx%v (got %vx%v)", web.MaxIconUploadDim, web.MaxIconUploadDim, conf.Width, conf.Height)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("cannot read icon: %v", err)
+	}
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid icon: %v", err)
+	}
+	return dogeicon.Encode(img, dogeicon.StyleAuto)
+}
+
+func newIdentityTable() *tabwriter.Writer {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "IDENTITY\tNAME\tCOUNTRY\tCITY\tSIGNED")
+	return tw
+}
+
+func printIdentityRow(tw *tabwriter.Writer, id spec.Identity) {
+	msg, ok := profile.DecodeIdentity(id.Payload)
+	if !ok {
+		fmt.Fprintf(tw, "%v\t(malformed)\t\t\t%v\n", hex.EncodeToString(id.PubKey), formatTime(id.Time))
+		return
+	}
+	fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", hex.EncodeToString(id.PubKey), msg.Name, strings.TrimRight(msg.Country, "\x00"), msg.City, formatTime(id.Time))
+}
+
+func formatTime(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
@@ -6,11 +6,16 @@ require code.dogecoin.org/gossip v0.0.18
 
 require github.com/mattn/go-sqlite3 v1.14.22
 
+require github.com/gorilla/websocket v1.5.0
+
+require golang.org/x/text v0.17.0
+
+require github.com/dogeorg/doge v0.0.12
+
 require (
 	github.com/btcsuite/golangcrypto v0.0.0-20150304025918-53f62d9b43e8 // indirect
 	github.com/decred/dcrd/crypto/blake256 v1.1.0 // indirect
 	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.3.0 // indirect
-	github.com/dogeorg/doge v0.0.12 // indirect
 	github.com/mr-tron/base58 v1.2.0 // indirect
 	github.com/rs/cors v1.11.1
 )
@@ -2,18 +2,31 @@ package announce
 
 import (
 	"bytes"
+	"fmt"
 	"log"
 	"time"
 
 	"code.dogecoin.org/gossip/dnet"
 	"code.dogecoin.org/gossip/iden"
 	"code.dogecoin.org/governor"
+	"code.dogecoin.org/identity/internal/config"
+	"code.dogecoin.org/identity/internal/metrics"
 	"code.dogecoin.org/identity/internal/spec"
 )
 
-const AnnounceLongevity = 24 * time.Hour
 const QueueAnnouncement = 10 * time.Second
 
+// The command line stores profiles directly in the database, so check the
+// stored profile for changes this often.
+const ProfileCheckInterval = time.Minute
+
+// Reasons the announcement is pending (see spec.Status)
+const (
+	PendingNoProfile = "no valid profile set"
+	PendingNoNodes   = "waiting for a node pubkey"
+	PendingQueued    = "profile changed, re-signing soon"
+)
+
 type Announce struct {
 	governor.ServiceCtx
 	_store       spec.Store
@@ -23,10 +36,14 @@ type Announce struct {
 	receiver     chan dnet.RawMessage // output: receives new announcement RawMessages
 	profile      iden.IdentityMsg     // next identity profile to encode and sign
 	profileValid bool                 // we have stored profile
+	status       *spec.Status         // output: our current announcement
+	settings     *config.Settings     // reloadable settings (announce interval)
 }
 
-func New(idenKey dnet.KeyPair, store spec.Store, receiver chan dnet.RawMessage, changes chan any) *Announce {
+func New(idenKey dnet.KeyPair, store spec.Store, receiver chan dnet.RawMessage, changes chan any, status *spec.Status, settings *config.Settings) *Announce {
 	return &Announce{
+		settings: settings,
+		status:   status,
 		_store:   store,
 		idenKey:  idenKey,
 		changes:  changes,
@@ -42,65 +59,71 @@ func (ns *Announce) Run() {
 }
 
 func (ns *Announce) updateAnnounce() {
-	remain := AnnounceLongevity
+	remain := ns.settings.AnnounceInterval()
 	if ns.profileValid {
 		msg, rem, ok := ns.loadOrGenerateAnnounce()
 		remain = rem
 		if ok {
 			ns.receiver <- msg
 		}
+	} else {
+		ns.status.SetPending(PendingNoProfile)
 	}
 	timer := time.NewTimer(remain)
+	setNextSign(remain)
+	check := time.NewTicker(ProfileCheckInterval)
+	defer check.Stop()
 	for !ns.Stopping() {
+		changed := false
 		select {
 		case change := <-ns.changes:
-			changed := false
 			switch msg := change.(type) {
 			case spec.Profile:
 				// new profile from web API (already stored in db)
-				newIden := iden.IdentityMsg{
-					Time:    dnet.DogeNow(),
-					Name:    msg.Name,
-					Bio:     msg.Bio,
-					Lat:     int16(msg.Lat),
-					Long:    int16(msg.Lon),
-					Country: msg.Country,
-					City:    msg.City,
-					Nodes:   ns.profile.Nodes, // preserve nodeList
-					Icon:    msg.Icon,
-				}
-				if newIden.IsValid() {
-					log.Printf("[announce] received new profile: %v %v %v %v %v", msg.Name, msg.Lat, msg.Lon, newIden.Lat, newIden.Long)
-					ns.profile = newIden
-					ns.profileValid = true
-					changed = true
-				} else {
-					log.Printf("[announce] received invalid profile (ingored)")
-				}
+				changed = ns.setProfile(msg)
 			case spec.NodePubKeyMsg:
 				log.Printf("[announce] received node pubkey: %x", msg.PubKey)
 				if !ns.nodeListContains(msg.PubKey) {
 					ns.profile.Nodes = append(ns.profile.Nodes, msg.PubKey)
 					changed = true
-					err := ns.store.AddProfileNode(msg.PubKey)
-					if err != nil {
-						log.Printf("[announce] cannot save announcement node: '%x': %v", msg.PubKey, err)
-					}
+				}
+				// also refreshes the time the node was last seen
+				err := ns.store.AddProfileNode(msg.PubKey)
+				if err != nil {
+					log.Printf("[announce] cannot save announcement node: '%x': %v", msg.PubKey, err)
+				}
+			case spec.NodesChangedMsg:
+				// node list edited via web API (already stored in db)
+				nodeList, err := ns.store.GetProfileNodes()
+				if err != nil {
+					log.Printf("[announce] cannot load profile nodes: %v", err)
+					break
+				}
+				if !sameNodes(ns.profile.Nodes, nodeList) {
+					log.Printf("[announce] node list changed: %v nodes", len(nodeList))
+					ns.profile.Nodes = nodeList
+					changed = true
 				}
 			default:
 				log.Printf("[announce] received unknown change: %v", msg)
 			}
-			// whenever a change is received, re-sign and gossip the announcement.
-			if changed {
-				if !timer.Stop() {
-					<-timer.C
+
+		case <-check.C:
+			// profile stored by the command line
+			p, err := ns.store.GetProfile()
+			if err != nil {
+				if !spec.IsNotFoundError(err) {
+					log.Printf("[announce] cannot load profile: %v", err)
 				}
-				timer.Reset(QueueAnnouncement)
+				break
+			}
+			if !sameProfile(ns.profile, IdentityFromProfile(p, nil)) {
+				changed = ns.setProfile(p)
 			}
 
 		case <-timer.C:
 			// every 24 hours, re-sign and gossip the announcement.
-			remain := AnnounceLongevity
+			remain := ns.settings.AnnounceInterval()
 			if ns.profileValid {
 				msg, rem, ok := ns.generateAnnounce(ns.profile)
 				remain = rem
@@ -108,15 +131,51 @@ func (ns *Announce) updateAnnounce() {
 					ns.receiver <- msg
 					log.Printf("[announce] sending announcement to all peers")
 				}
+			} else {
+				ns.status.SetPending(PendingNoProfile)
 			}
 			// restart the timer
 			timer.Reset(remain)
+			setNextSign(remain)
 
 		case <-ns.Context.Done():
 			timer.Stop()
 			return
 		}
+		// whenever a change is received, re-sign and gossip the announcement.
+		if changed {
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(QueueAnnouncement)
+			setNextSign(QueueAnnouncement)
+			ns.status.SetPending(PendingQueued)
+		}
+	}
+}
+
+// setProfile replaces the profile to announce (false if invalid)
+func (ns *Announce) setProfile(p spec.Profile) bool {
+	newIden := IdentityFromProfile(p, ns.profile.Nodes) // preserve nodeList
+	newIden.Time = dnet.DogeNow()
+	if !newIden.IsValid() {
+		log.Printf("[announce] received invalid profile (ingored)")
+		return false
 	}
+	log.Printf("[announce] received new profile: %v %v %v %v %v", p.Name, p.Lat, p.Lon, newIden.Lat, newIden.Long)
+	ns.profile = newIden
+	ns.profileValid = true
+	return true
+}
+
+// sameProfile compares the profile fields (not the time or nodes)
+func sameProfile(a iden.IdentityMsg, b iden.IdentityMsg) bool {
+	return a.Name == b.Name && a.Bio == b.Bio && a.Lat == b.Lat && a.Long == b.Long &&
+		a.Country == b.Country && a.City == b.City && bytes.Equal(a.Icon, b.Icon)
+}
+
+func setNextSign(remain time.Duration) {
+	metrics.AnnounceNextSign.Set(float64(time.Now().Add(remain).Unix()))
 }
 
 func (ns *Announce) nodeListContains(key []byte) bool {
@@ -129,6 +188,26 @@ func (ns *Announce) nodeListContains(key []byte) bool {
 	return false
 }
 
+// sameNodes is true if both lists contain the same node pubkeys (in any order)
+func sameNodes(a [][]byte, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, k := range a {
+		found := false
+		for _, j := range b {
+			if bytes.Equal(k, j) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 func (ns *Announce) loadOrGenerateAnnounce() (msg dnet.RawMessage, remaining time.Duration, isValid bool) {
 	defer func() {
 		if err := recover(); err != nil {
@@ -156,6 +235,9 @@ func (ns *Announce) loadOrGenerateAnnounce() (msg dnet.RawMessage, remaining tim
 			msg = dnet.ReEncodeMessage(dnet.ChannelIdentity, iden.TagIdentity, ns.idenKey.Pub, sig, oldPayload)
 			remaining = time.Duration(expires-now) * time.Second
 			isValid = true
+			metrics.AnnounceTime.Set(float64(oldMsg.Time.Local().Unix()))
+			ns.status.SetAnnounce(oldMsg.Time.Local().Unix(), expires)
+			ns.status.SetPending("")
 			return
 		}
 	}
@@ -164,25 +246,35 @@ func (ns *Announce) loadOrGenerateAnnounce() (msg dnet.RawMessage, remaining tim
 }
 
 func (ns *Announce) generateAnnounce(profile iden.IdentityMsg) (dnet.RawMessage, time.Duration, bool) {
+	interval := ns.settings.AnnounceInterval()
 	// wait for at least one node pubkey.
 	// an identity without any nodes is useless, and if we sign an identity
 	// now, it will be invalidated when we add the local node's pubkey.
-	if !(profile.IsValid() && len(profile.Nodes) > 0) {
-		return dnet.RawMessage{}, AnnounceLongevity, false
+	if !profile.IsValid() {
+		ns.status.SetPending(PendingNoProfile)
+		return dnet.RawMessage{}, interval, false
+	}
+	if len(profile.Nodes) == 0 {
+		ns.status.SetPending(PendingNoNodes)
+		return dnet.RawMessage{}, interval, false
 	}
 
 	// create and sign the new announcement.
 	log.Printf("[announce] signing a new announcement")
 	now := time.Now()
 	profile.Time = dnet.UnixToDoge(now)
-	payload := profile.Encode()
-	msg := dnet.EncodeMessage(dnet.ChannelIdentity, iden.TagIdentity, ns.idenKey, payload)
-	view := dnet.MsgView(msg)
+	payload, err := EncodeIdentity(&profile)
+	if err != nil {
+		log.Printf("[announce] cannot encode announcement: %v", err)
+		ns.status.SetPending(err.Error())
+		return dnet.RawMessage{}, interval, false
+	}
+	view := SignIdentity(ns.idenKey, payload)
 	sig := view.Signature()[:]
 
 	// store the announcement to re-use on next startup.
-	expires := now.Add(AnnounceLongevity).Unix()
-	err := ns.store.SetAnnounce(payload, sig, expires)
+	expires := now.Add(interval).Unix()
+	err = ns.store.SetAnnounce(payload, sig, expires)
 	if err != nil {
 		log.Printf("[announce] cannot store announcement: %v", err)
 	}
@@ -195,7 +287,10 @@ func (ns *Announce) generateAnnounce(profile iden.IdentityMsg) (dnet.RawMessage,
 		log.Printf("[announce] cannot store announcement: %v", err)
 	}
 
-	return dnet.RawMessage{Header: view.Header(), Payload: payload}, AnnounceLongevity, true
+	metrics.AnnounceTime.Set(float64(now.Unix()))
+	ns.status.SetAnnounce(now.Unix(), expires)
+	ns.status.SetPending("")
+	return dnet.RawMessage{Header: view.Header(), Payload: payload}, interval, true
 }
 
 func (ns *Announce) loadProfile() {
@@ -214,15 +309,36 @@ func (ns *Announce) loadProfile() {
 		log.Printf("[announce] cannot load profile nodes: %v", err)
 		return
 	}
-	ns.profile = iden.IdentityMsg{
+	ns.profile = IdentityFromProfile(p, nodeList)
+	ns.profileValid = ns.profile.IsValid()
+}
+
+// IdentityFromProfile builds the IdentityMsg to announce for a profile.
+func IdentityFromProfile(p spec.Profile, nodes [][]byte) iden.IdentityMsg {
+	return iden.IdentityMsg{
 		Name:    p.Name,
 		Bio:     p.Bio,
 		Lat:     int16(p.Lat),
 		Long:    int16(p.Lon),
 		Country: p.Country,
 		City:    p.City,
-		Nodes:   nodeList,
+		Nodes:   nodes,
 		Icon:    p.Icon,
 	}
-	ns.profileValid = ns.profile.IsValid()
+}
+
+// EncodeIdentity encodes an IdentityMsg, returning an error where the
+// encoder would panic (e.g. field too long, or a country that isn't 2 bytes)
+func EncodeIdentity(msg *iden.IdentityMsg) (payload []byte, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			payload, err = nil, fmt.Errorf("cannot encode identity: %v", e)
+		}
+	}()
+	return msg.Encode(), nil
+}
+
+// SignIdentity signs an encoded IdentityMsg with the identity key.
+func SignIdentity(idenKey dnet.KeyPair, payload []byte) dnet.MessageView {
+	return dnet.MsgView(dnet.EncodeMessage(dnet.ChannelIdentity, iden.TagIdentity, idenKey, payload))
 }
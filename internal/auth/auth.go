@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Admin token: a random secret generated into the storage directory,
+// required as "Authorization: Bearer <token>" on mutating web endpoints.
+
+const TokenFileName = "admin.token"
+const TokenBytes = 32
+
+// LoadOrCreateToken reads the admin token file, creating it if missing.
+func LoadOrCreateToken(fileName string) (token string, created bool, err error) {
+	data, err := os.ReadFile(fileName)
+	if err == nil {
+		token = strings.TrimSpace(string(data))
+		if len(token) < TokenBytes {
+			return "", false, fmt.Errorf("admin token is too short: %v", fileName)
+		}
+		return token, false, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", false, err
+	}
+	var buf [TokenBytes]byte
+	_, err = rand.Read(buf[:])
+	if err != nil {
+		return "", false, err
+	}
+	token = hex.EncodeToString(buf[:])
+	// O_EXCL: never overwrite a token created concurrently
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", false, err
+	}
+	_, err = file.WriteString(token + "\n")
+	if err == nil {
+		err = file.Close()
+	} else {
+		file.Close()
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return token, true, nil
+}
+
+// Check returns true if the request carries the admin token
+// in an "Authorization: Bearer <token>" header.
+func Check(r *http.Request, token string) bool {
+	return matches(bearerToken(r), token)
+}
+
+// CheckWebSocket also accepts the admin token in the "token" query
+// parameter, because browsers cannot set headers on a WebSocket.
+func CheckWebSocket(r *http.Request, token string) bool {
+	got := bearerToken(r)
+	if got == "" {
+		got = r.URL.Query().Get("token")
+	}
+	return matches(got, token)
+}
+
+func bearerToken(r *http.Request) string {
+	hdr := r.Header.Get("Authorization")
+	if len(hdr) > 7 && strings.EqualFold(hdr[:7], "Bearer ") {
+		return strings.TrimSpace(hdr[7:])
+	}
+	return ""
+}
+
+func matches(got string, token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
@@ -0,0 +1,321 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// The config file is JSON in the storage directory (config.json). Every
+// setting is optional, and command-line flags override the file.
+//
+// Binds, the handler socket, the web directory, TLS and the key source are
+// read at startup; the other settings are reloaded on SIGHUP. Identity
+// expiry is not a setting because it must be the same on every node (see
+// spec.ExpiryTime).
+
+const FileName = "config.json"
+
+// Defaults for settings missing from the config file.
+const (
+	DefaultPort             = 8099
+	DefaultKeyEnv           = "KEY"
+	DefaultAnnounceInterval = 24 * time.Hour   // re-sign and gossip our identity
+	DefaultGossipInterval   = 71 * time.Second // gossip a random identity to peers
+	DefaultMaxIconUpload    = 8 << 20          // bytes
+	DefaultMaxImport        = 1 << 20          // bytes
+	DefaultNearbyMaxLimit   = 1000
+)
+
+// File is the config file.
+type File struct {
+	// read at startup
+	Bind    []string `json:"bind,omitempty"`    // web <ip>:<port> or /unix/path (default 0.0.0.0:<port>)
+	Port    int      `json:"port,omitempty"`    // web port for binds without one
+	Handler string   `json:"handler,omitempty"` // dogenet handler <ip>:<port> or /unix/path
+	Web     string   `json:"web,omitempty"`     // web directory
+	TLS     *bool    `json:"tls,omitempty"`     // serve <ip>:<port> binds over TLS
+	KeyEnv  string   `json:"keyEnv,omitempty"`  // env-var holding the identity private key (hex)
+	KeyFile string   `json:"keyFile,omitempty"` // or a file holding it (not readable by others)
+
+	// reloaded on SIGHUP
+	CORS             []string `json:"cors,omitempty"`             // allowed cross-origin callers ("*" for any)
+	Blocklist        string   `json:"blocklist,omitempty"`        // blocklist file to import
+	AnnounceInterval Duration `json:"announceInterval,omitempty"` // e.g. "24h"
+	GossipInterval   Duration `json:"gossipInterval,omitempty"`   // e.g. "71s"
+	Limits           Limits   `json:"limits"`
+}
+
+// Limits on web API requests (zero for the default)
+type Limits struct {
+	MaxIconUpload  int64 `json:"maxIconUpload,omitempty"`  // bytes (POST /profile/icon)
+	MaxImport      int64 `json:"maxImport,omitempty"`      // bytes (POST /policy/import, /profile/vcard)
+	NearbyMaxLimit int   `json:"nearbyMaxLimit,omitempty"` // identities per GET /nearby
+}
+
+// Duration is a time.Duration written as a string, e.g. "90s" or "24h".
+type Duration string
+
+// Get parses the duration (zero if empty)
+func (d Duration) Get() (time.Duration, error) {
+	if d == "" {
+		return 0, nil
+	}
+	dur, err := time.ParseDuration(string(d))
+	if err != nil {
+		return 0, fmt.Errorf("expecting a duration like \"90s\" or \"24h\" (got %q)", string(d))
+	}
+	return dur, nil
+}
+
+// Load reads a config file (see Validate); a missing file is an empty
+// config unless required.
+func Load(filename string, required bool) (File, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return File{}, nil
+		}
+		return File{}, fmt.Errorf("cannot read config: %v", err)
+	}
+	var f File
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields() // catch misspelled settings
+	err = dec.Decode(&f)
+	if err != nil {
+		return File{}, fmt.Errorf("%v: %v", filename, decodeError(data, err))
+	}
+	return f, nil
+}
+
+// decodeError adds the line number to a JSON syntax or type error.
+func decodeError(data []byte, err error) error {
+	var syntax *json.SyntaxError
+	if errors.As(err, &syntax) {
+		line := bytes.Count(data[:syntax.Offset], []byte("\n")) + 1
+		return fmt.Errorf("line %v: %v", line, err)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		line := bytes.Count(data[:typeErr.Offset], []byte("\n")) + 1
+		return fmt.Errorf("line %v: %v", line, err)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		line := bytes.Count(data, []byte("\n")) + 1
+		return fmt.Errorf("line %v: unexpected end of file", line)
+	}
+	return err
+}
+
+// Override returns the config with the settings given in o (e.g. flags) replaced.
+func (f File) Override(o File) File {
+	if o.Bind != nil {
+		f.Bind = o.Bind
+	}
+	if o.Port != 0 {
+		f.Port = o.Port
+	}
+	if o.Handler != "" {
+		f.Handler = o.Handler
+	}
+	if o.Web != "" {
+		f.Web = o.Web
+	}
+	if o.TLS != nil {
+		f.TLS = o.TLS
+	}
+	if o.KeyEnv != "" || o.KeyFile != "" {
+		f.KeyEnv, f.KeyFile = o.KeyEnv, o.KeyFile
+	}
+	if o.CORS != nil {
+		f.CORS = o.CORS
+	}
+	if o.Blocklist != "" {
+		f.Blocklist = o.Blocklist
+	}
+	if o.AnnounceInterval != "" {
+		f.AnnounceInterval = o.AnnounceInterval
+	}
+	if o.GossipInterval != "" {
+		f.GossipInterval = o.GossipInterval
+	}
+	if o.Limits.MaxIconUpload != 0 {
+		f.Limits.MaxIconUpload = o.Limits.MaxIconUpload
+	}
+	if o.Limits.MaxImport != 0 {
+		f.Limits.MaxImport = o.Limits.MaxImport
+	}
+	if o.Limits.NearbyMaxLimit != 0 {
+		f.Limits.NearbyMaxLimit = o.Limits.NearbyMaxLimit
+	}
+	return f
+}
+
+// Validate checks the settings (bind addresses are checked when parsed)
+func (f *File) Validate() error {
+	if f.Port < 0 || f.Port > 65535 {
+		return fmt.Errorf("port: expecting 1-65535 (got %v)", f.Port)
+	}
+	if f.KeyEnv != "" && f.KeyFile != "" {
+		return errors.New("keyEnv, keyFile: expecting one key source (got both)")
+	}
+	for _, origin := range f.CORS {
+		if err := checkOrigin(origin); err != nil {
+			return fmt.Errorf("cors: %v", err)
+		}
+	}
+	if err := checkDuration("announceInterval", f.AnnounceInterval, time.Hour, 7*24*time.Hour); err != nil {
+		return err
+	}
+	if err := checkDuration("gossipInterval", f.GossipInterval, 10*time.Second, time.Hour); err != nil {
+		return err
+	}
+	if err := checkLimit("limits.maxIconUpload", f.Limits.MaxIconUpload, 64<<10, 64<<20); err != nil {
+		return err
+	}
+	if err := checkLimit("limits.maxImport", f.Limits.MaxImport, 1<<10, 64<<20); err != nil {
+		return err
+	}
+	if err := checkLimit("limits.nearbyMaxLimit", int64(f.Limits.NearbyMaxLimit), 1, 10000); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkDuration checks an optional duration (zero for the default)
+func checkDuration(name string, val Duration, min time.Duration, max time.Duration) error {
+	dur, err := val.Get()
+	if err != nil {
+		return fmt.Errorf("%v: %v", name, err)
+	}
+	if dur != 0 && (dur < min || dur > max) {
+		return fmt.Errorf("%v: expecting %v to %v (got %v)", name, min, max, dur)
+	}
+	return nil
+}
+
+// checkLimit checks an optional limit (zero for the default)
+func checkLimit(name string, val int64, min int64, max int64) error {
+	if val != 0 && (val < min || val > max) {
+		return fmt.Errorf("%v: expecting %v to %v (got %v)", name, min, max, val)
+	}
+	return nil
+}
+
+func checkOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+		return fmt.Errorf("expecting * or an origin like https://example.org (got %q)", origin)
+	}
+	return nil
+}
+
+// RestartNeeded lists the settings read at startup that differ.
+func RestartNeeded(running File, next File) (names []string) {
+	if !reflect.DeepEqual(running.Bind, next.Bind) {
+		names = append(names, "bind")
+	}
+	if running.Port != next.Port {
+		names = append(names, "port")
+	}
+	if running.Handler != next.Handler {
+		names = append(names, "handler")
+	}
+	if running.Web != next.Web {
+		names = append(names, "web")
+	}
+	if running.UseTLS() != next.UseTLS() {
+		names = append(names, "tls")
+	}
+	if running.KeyEnv != next.KeyEnv || running.KeyFile != next.KeyFile {
+		names = append(names, "key source")
+	}
+	return
+}
+
+func (f *File) UseTLS() bool {
+	return f.TLS != nil && *f.TLS
+}
+
+// Settings are the reloadable settings, shared between services.
+type Settings struct {
+	mu               sync.RWMutex
+	cors             map[string]bool
+	announceInterval time.Duration
+	gossipInterval   time.Duration
+	limits           Limits
+}
+
+func NewSettings() *Settings {
+	s := &Settings{}
+	s.Apply(File{})
+	return s
+}
+
+// Apply replaces the settings (missing settings take their defaults);
+// the config must be valid.
+func (s *Settings) Apply(f File) {
+	cors := make(map[string]bool, len(f.CORS))
+	for _, origin := range f.CORS {
+		cors[origin] = true
+	}
+	limits := f.Limits
+	if limits.MaxIconUpload == 0 {
+		limits.MaxIconUpload = DefaultMaxIconUpload
+	}
+	if limits.MaxImport == 0 {
+		limits.MaxImport = DefaultMaxImport
+	}
+	if limits.NearbyMaxLimit == 0 {
+		limits.NearbyMaxLimit = DefaultNearbyMaxLimit
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cors = cors
+	s.announceInterval = orDefault(f.AnnounceInterval, DefaultAnnounceInterval)
+	s.gossipInterval = orDefault(f.GossipInterval, DefaultGossipInterval)
+	s.limits = limits
+}
+
+// orDefault returns the duration, or def if empty (or invalid: see Validate)
+func orDefault(val Duration, def time.Duration) time.Duration {
+	if dur, err := val.Get(); err == nil && dur != 0 {
+		return dur
+	}
+	return def
+}
+
+// AllowOrigin checks a cross-origin caller against the CORS setting.
+func (s *Settings) AllowOrigin(origin string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cors["*"] || s.cors[origin]
+}
+
+func (s *Settings) AnnounceInterval() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.announceInterval
+}
+
+func (s *Settings) GossipInterval() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.gossipInterval
+}
+
+func (s *Settings) Limits() Limits {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.limits
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string // error substring ("" if valid)
+	}{
+		{"empty", `{}`, ""},
+		{"settings", `{"port": 8100, "cors": ["https://example.org"], "limits": {"maxImport": 2048}}`, ""},
+		{"unknown", "{\n  \"prot\": 8100\n}", `unknown field "prot"`},
+		{"unknown limit", `{"limits": {"maxIcon": 1}}`, `unknown field "maxIcon"`},
+		{"syntax", "{\n  \"port\": 8100,\n  \"bind\": [,]\n}", "line 3: invalid character ','"},
+		{"missing comma", "{\n  \"port\": 8100\n  \"web\": \"./web\"\n}", "line 3: invalid character '\"'"},
+		{"first line", `{"port" 8100}`, "line 1: invalid character '8'"},
+		{"truncated", "{\n  \"port\": 8100,", "line 2: unexpected end of file"},
+		{"type", "{\n  \"cors\": [],\n  \"port\": \"8100\"\n}", "line 3: json: cannot unmarshal string"},
+	}
+	dir := t.TempDir()
+	for _, test := range tests {
+		filename := filepath.Join(dir, FileName)
+		if err := os.WriteFile(filename, []byte(test.data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load(filename, true)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%v: no error, want error containing %q", test.name, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%v: error %q, want error containing %q", test.name, err, test.err)
+		}
+	}
+	// a missing file is only an error when required
+	missing := filepath.Join(dir, "missing.json")
+	if f, err := Load(missing, false); err != nil || !reflect.DeepEqual(f, File{}) {
+		t.Errorf("Load(missing, false) = %+v, %v; want empty config", f, err)
+	}
+	if _, err := Load(missing, true); err == nil {
+		t.Errorf("Load(missing, true): no error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		f    File
+		err  string // error substring ("" if valid)
+	}{
+		{"empty", File{}, ""},
+		{"port", File{Port: 65535}, ""},
+		{"port range", File{Port: 65536}, "port: expecting 1-65535"},
+		{"negative port", File{Port: -1}, "port:"},
+		{"key sources", File{KeyEnv: "KEY", KeyFile: "key.hex"}, "expecting one key source"},
+		{"any origin", File{CORS: []string{"*"}}, ""},
+		{"origins", File{CORS: []string{"https://example.org", "http://localhost:3000"}}, ""},
+		{"origin path", File{CORS: []string{"https://example.org/app"}}, "cors: expecting * or an origin"},
+		{"origin scheme", File{CORS: []string{"example.org"}}, "cors:"},
+		{"origin query", File{CORS: []string{"https://example.org?x=1"}}, "cors:"},
+		{"announce", File{AnnounceInterval: "12h"}, ""},
+		{"announce short", File{AnnounceInterval: "59m"}, "announceInterval: expecting 1h0m0s to 168h0m0s"},
+		{"announce long", File{AnnounceInterval: "169h"}, "announceInterval:"},
+		{"announce syntax", File{AnnounceInterval: "1 day"}, "announceInterval: expecting a duration"},
+		{"gossip", File{GossipInterval: "10s"}, ""},
+		{"gossip short", File{GossipInterval: "9s"}, "gossipInterval:"},
+		{"icon upload", File{Limits: Limits{MaxIconUpload: 64 << 10}}, ""},
+		{"icon upload small", File{Limits: Limits{MaxIconUpload: 64<<10 - 1}}, "limits.maxIconUpload:"},
+		{"import large", File{Limits: Limits{MaxImport: 64<<20 + 1}}, "limits.maxImport:"},
+		{"nearby", File{Limits: Limits{NearbyMaxLimit: 10000}}, ""},
+		{"nearby large", File{Limits: Limits{NearbyMaxLimit: 10001}}, "limits.nearbyMaxLimit:"},
+		{"nearby negative", File{Limits: Limits{NearbyMaxLimit: -1}}, "limits.nearbyMaxLimit:"},
+	}
+	for _, test := range tests {
+		err := test.f.Validate()
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%v: no error, want error containing %q", test.name, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%v: error %q, want error containing %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestOverride(t *testing.T) {
+	yes, no := true, false
+	file := File{
+		Bind:             []string{"0.0.0.0:8099"},
+		Port:             8099,
+		Handler:          "/tmp/dogenet.sock",
+		TLS:              &yes,
+		KeyFile:          "key.hex",
+		CORS:             []string{"https://example.org"},
+		AnnounceInterval: "12h",
+		Limits:           Limits{MaxImport: 2048, NearbyMaxLimit: 50},
+	}
+	tests := []struct {
+		name  string
+		flags File
+		want  File
+	}{
+		{"no flags", File{}, file},
+		{"flags win", File{
+			Bind:           []string{"127.0.0.1:9000", "/tmp/web.sock"},
+			Handler:        "127.0.0.1:8085",
+			TLS:            &no,
+			CORS:           []string{"*"},
+			GossipInterval: "30s",
+			Limits:         Limits{MaxImport: 4096},
+		}, File{
+			Bind:             []string{"127.0.0.1:9000", "/tmp/web.sock"},
+			Port:             8099,
+			Handler:          "127.0.0.1:8085",
+			TLS:              &no,
+			KeyFile:          "key.hex",
+			CORS:             []string{"*"},
+			AnnounceInterval: "12h",
+			GossipInterval:   "30s",
+			Limits:           Limits{MaxImport: 4096, NearbyMaxLimit: 50},
+		}},
+		// a key source flag replaces the file's key source
+		{"key source", File{KeyEnv: "OTHER_KEY"}, func() File { f := file; f.KeyEnv, f.KeyFile = "OTHER_KEY", ""; return f }()},
+	}
+	for _, test := range tests {
+		got := file.Override(test.flags)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: Override = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRestartNeeded(t *testing.T) {
+	yes, no := true, false
+	running := File{Bind: []string{"0.0.0.0:8099"}, Handler: "/tmp/dogenet.sock", TLS: &no, KeyEnv: "KEY"}
+	tests := []struct {
+		name string
+		next File
+		want []string
+	}{
+		{"same", running, nil},
+		{"reloadable", func() File {
+			f := running
+			f.CORS, f.GossipInterval, f.Limits.MaxImport = []string{"*"}, "30s", 2048
+			return f
+		}(), nil},
+		{"tls unset is off", File{Bind: []string{"0.0.0.0:8099"}, Handler: "/tmp/dogenet.sock", KeyEnv: "KEY"}, nil},
+		{"startup", File{Bind: []string{"127.0.0.1:8099"}, Port: 8100, Handler: "127.0.0.1:8085", Web: "./www", TLS: &yes, KeyFile: "key.hex"},
+			[]string{"bind", "port", "handler", "web", "tls", "key source"}},
+	}
+	for _, test := range tests {
+		got := RestartNeeded(running, test.next)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: RestartNeeded = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
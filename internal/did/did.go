@@ -0,0 +1,144 @@
+package did
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.dogecoin.org/gossip/iden"
+)
+
+// The did:doge method maps a Dogecoin identity pubkey to a DID:
+//
+//	did:doge:<identity pubkey as 64 lower-case hex digits>
+//
+// The DID document is built from the identity's signed IdentityMsg:
+// the identity key is the verification method, each claimed node is a
+// service, and the profile fields are properties of the document.
+// An identity is deactivated when it expires (30 days after signing)
+// or is re-signed without any nodes.
+
+const Prefix = "did:doge:"
+
+// Contexts: the DID core context, then our own terms.
+var Context = []any{
+	"https://www.w3.org/ns/did/v1",
+	map[string]string{"@vocab": "https://code.dogecoin.org/identity/did#"},
+}
+
+const KeyType = "DogeIdentityVerificationKey" // Schnorr secp256k1 x-only key (blake256 message hash)
+const NodeServiceType = "DogeNode"
+
+// Document is a DID document for an identity.
+type Document struct {
+	Context            []any                `json:"@context"`
+	ID                 string               `json:"id"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod,omitempty"`
+	Authentication     []string             `json:"authentication,omitempty"`
+	AssertionMethod    []string             `json:"assertionMethod,omitempty"`
+	Service            []Service            `json:"service,omitempty"`
+	Profile            *Profile             `json:"profile,omitempty"`
+}
+
+type VerificationMethod struct {
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	Controller   string `json:"controller"`
+	PublicKeyHex string `json:"publicKeyHex"`
+}
+
+type Service struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	ServiceEndpoint string `json:"serviceEndpoint"` // dogenet:<node pubkey hex>
+	Verified        bool   `json:"verified"`        // node also claims this identity
+}
+
+// Profile holds the identity's announced profile fields.
+type Profile struct {
+	Name    string `json:"name"`
+	Bio     string `json:"bio,omitempty"`
+	Country string `json:"country,omitempty"`
+	City    string `json:"city,omitempty"`
+	Lat     string `json:"lat"` // WGS84 degrees
+	Lon     string `json:"lon"` // WGS84 degrees
+}
+
+// Metadata is the DID document metadata.
+type Metadata struct {
+	Updated     string `json:"updated,omitempty"` // when the identity was signed (RFC3339)
+	Expires     string `json:"expires,omitempty"` // when it expires unless re-signed (RFC3339)
+	Deactivated bool   `json:"deactivated"`
+}
+
+// FromPubKey returns the DID of an identity pubkey.
+func FromPubKey(idenPub []byte) string {
+	return Prefix + hex.EncodeToString(idenPub)
+}
+
+// Parse returns the identity pubkey of a did:doge DID.
+func Parse(did string) ([]byte, error) {
+	if !strings.HasPrefix(did, Prefix) {
+		return nil, fmt.Errorf("invalid DID: expecting %v<hex> (got %q)", Prefix, did)
+	}
+	id := strings.TrimPrefix(did, Prefix)
+	pub, err := hex.DecodeString(id)
+	if err != nil || len(pub) != 32 || id != strings.ToLower(id) {
+		return nil, fmt.Errorf("invalid DID: expecting 32 bytes lower-case hex after %v (got %q)", Prefix, id)
+	}
+	return pub, nil
+}
+
+// NewDocument builds the DID document of a stored identity;
+// verified[i] is true if node i also claims the identity.
+func NewDocument(idenPub []byte, msg *iden.IdentityMsg, verified []bool) Document {
+	id := FromPubKey(idenPub)
+	key := id + "#identity"
+	doc := Document{
+		Context: Context,
+		ID:      id,
+		VerificationMethod: []VerificationMethod{{
+			ID:           key,
+			Type:         KeyType,
+			Controller:   id,
+			PublicKeyHex: hex.EncodeToString(idenPub),
+		}},
+		Authentication:  []string{key},
+		AssertionMethod: []string{key},
+		Profile: &Profile{
+			Name:    msg.Name,
+			Bio:     msg.Bio,
+			Country: strings.TrimRight(msg.Country, "\x00"),
+			City:    msg.City,
+			Lat:     strconv.FormatFloat(float64(msg.Lat)/10.0, 'f', 1, 64),  // undo quantization
+			Lon:     strconv.FormatFloat(float64(msg.Long)/10.0, 'f', 1, 64), // undo quantization
+		},
+	}
+	for i, node := range msg.Nodes {
+		doc.Service = append(doc.Service, Service{
+			ID:              fmt.Sprintf("%v#node-%v", id, i+1),
+			Type:            NodeServiceType,
+			ServiceEndpoint: "dogenet:" + hex.EncodeToString(node),
+			Verified:        i < len(verified) && verified[i],
+		})
+	}
+	return doc
+}
+
+// Deactivated returns a document for an identity that is no longer
+// announced: it has no verification methods.
+func Deactivated(idenPub []byte) Document {
+	return Document{Context: Context, ID: FromPubKey(idenPub)}
+}
+
+// NewMetadata describes a stored identity signed at the given unix time.
+func NewMetadata(msg *iden.IdentityMsg, signed int64, expiry time.Duration, now time.Time) Metadata {
+	expires := time.Unix(signed, 0).Add(expiry)
+	return Metadata{
+		Updated:     time.Unix(signed, 0).UTC().Format(time.RFC3339),
+		Expires:     expires.UTC().Format(time.RFC3339),
+		Deactivated: len(msg.Nodes) == 0 || !now.Before(expires),
+	}
+}
@@ -0,0 +1,212 @@
+package dogeicon
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+
+	"code.dogecoin.org/gossip/dnet"
+	"code.dogecoin.org/gossip/icon"
+)
+
+// DogeIcon: 48x48 compressed Y'CbCr 4:2:0 image (see gossip/icon/dogeicon.go)
+//
+// Format: [1] style byte || [1584] compressed tiles
+//
+// Style bits: bit 0 = interpolation (0=flat 1=linear); other bits reserved.
+
+const IconDim = 48                     // width and height in pixels
+const IconSize = dnet.DogeIconSize + 1 // +1 for style byte
+const MinScale = 16                    // smallest rendered size in pixels
+const MaxScale = 768                   // largest rendered size in pixels
+
+// Filters for Scale
+const (
+	Nearest  = "nearest"  // keep the blocky pixel-art look
+	Bilinear = "bilinear" // smooth
+)
+
+var ErrNoIcon = errors.New("no icon")
+
+// Decode a compressed DogeIcon to a 48x48 image.
+func Decode(comp []byte) (*image.NRGBA, error) {
+	if len(comp) == 0 {
+		return nil, ErrNoIcon
+	}
+	if len(comp) != IconSize {
+		return nil, fmt.Errorf("invalid icon: expecting %v bytes (got %v)", IconSize, len(comp))
+	}
+	// the decoder requires one byte of padding at the end.
+	var buf [IconSize + 1]byte
+	copy(buf[:], comp)
+	rgb := icon.Uncompress(&buf)
+	img := image.NewNRGBA(image.Rect(0, 0, IconDim, IconDim))
+	for p, s := 0, 0; s < len(rgb); p, s = p+4, s+3 {
+		img.Pix[p] = rgb[s]
+		img.Pix[p+1] = rgb[s+1]
+		img.Pix[p+2] = rgb[s+2]
+		img.Pix[p+3] = 255
+	}
+	return img, nil
+}
+
+// Scale a square image to size x size pixels.
+func Scale(img *image.NRGBA, size int, filter string) (*image.NRGBA, error) {
+	if size < MinScale || size > MaxScale {
+		return nil, fmt.Errorf("invalid size: out of range [%v, %v] (got %v)", MinScale, MaxScale, size)
+	}
+	src := img.Bounds()
+	if size == src.Dx() && size == src.Dy() {
+		return img, nil
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	switch filter {
+	case Nearest, "":
+		for y := 0; y < size; y++ {
+			sy := src.Min.Y + y*src.Dy()/size
+			for x := 0; x < size; x++ {
+				sx := src.Min.X + x*src.Dx()/size
+				dst.SetNRGBA(x, y, img.NRGBAAt(sx, sy))
+			}
+		}
+	case Bilinear:
+		fx := float64(src.Dx()) / float64(size)
+		fy := float64(src.Dy()) / float64(size)
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				dst.SetNRGBA(x, y, bilinear(img, (float64(x)+0.5)*fx-0.5, (float64(y)+0.5)*fy-0.5))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid filter: expecting %v or %v (got %v)", Nearest, Bilinear, filter)
+	}
+	return dst, nil
+}
+
+// sample the image at a fractional position (pixel centres are at integers)
+func bilinear(img *image.NRGBA, x float64, y float64) color.NRGBA {
+	b := img.Bounds()
+	x0, y0 := floor(x), floor(y)
+	wx, wy := x-float64(x0), y-float64(y0)
+	c00 := img.NRGBAAt(clampInt(x0, b.Min.X, b.Max.X-1), clampInt(y0, b.Min.Y, b.Max.Y-1))
+	c10 := img.NRGBAAt(clampInt(x0+1, b.Min.X, b.Max.X-1), clampInt(y0, b.Min.Y, b.Max.Y-1))
+	c01 := img.NRGBAAt(clampInt(x0, b.Min.X, b.Max.X-1), clampInt(y0+1, b.Min.Y, b.Max.Y-1))
+	c11 := img.NRGBAAt(clampInt(x0+1, b.Min.X, b.Max.X-1), clampInt(y0+1, b.Min.Y, b.Max.Y-1))
+	lerp := func(a, b, c, d uint8) uint8 {
+		top := float64(a)*(1-wx) + float64(b)*wx
+		bot := float64(c)*(1-wx) + float64(d)*wx
+		return uint8(top*(1-wy) + bot*wy + 0.5)
+	}
+	return color.NRGBA{
+		R: lerp(c00.R, c10.R, c01.R, c11.R),
+		G: lerp(c00.G, c10.G, c01.G, c11.G),
+		B: lerp(c00.B, c10.B, c01.B, c11.B),
+		A: lerp(c00.A, c10.A, c01.A, c11.A),
+	}
+}
+
+func floor(v float64) int {
+	i := int(v)
+	if v < 0 && float64(i) != v {
+		i--
+	}
+	return i
+}
+
+func clampInt(v int, lo int, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// Styles for Encode
+const (
+	StyleFlat   = 0  // flat interpolation
+	StyleLinear = 1  // linear interpolation
+	StyleAuto   = -1 // choose the style with the least error
+)
+
+// Encode an image of any size as a compressed DogeIcon:
+// crops the centre square, scales to 48x48 and composites over white.
+func Encode(img image.Image, style int) ([]byte, error) {
+	if style != StyleFlat && style != StyleLinear && style != StyleAuto {
+		return nil, fmt.Errorf("invalid style: expecting %v, %v or auto (got %v)", StyleFlat, StyleLinear, style)
+	}
+	b := img.Bounds()
+	if b.Dx() < 1 || b.Dy() < 1 {
+		return nil, errors.New("invalid image: empty")
+	}
+	rgb := fit(img)
+	if style != StyleAuto {
+		comp := compress(rgb, byte(style))
+		return comp[:IconSize], nil
+	}
+	// try both and use the least-difference version
+	flat := compress(rgb, StyleFlat)
+	linear := compress(rgb, StyleLinear)
+	if sad(rgb, icon.Uncompress(&flat)) <= sad(rgb, icon.Uncompress(&linear)) {
+		return flat[:IconSize], nil
+	}
+	return linear[:IconSize], nil
+}
+
+func compress(rgb []byte, style byte) [IconSize + 1]byte {
+	const averageChroma = 8 // average the 4 chroma samples in each tile
+	return icon.Compress1(rgb, style, 3, averageChroma)
+}
+
+// fit crops the centre square of an image and box-filters it to 48x48 RGB.
+func fit(img image.Image) []byte {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	rgb := make([]byte, IconDim*IconDim*3)
+	for ty := 0; ty < IconDim; ty++ {
+		sy0, sy1 := y0+ty*side/IconDim, y0+(ty+1)*side/IconDim
+		if sy1 == sy0 {
+			sy1 = sy0 + 1 // upscaling
+		}
+		for tx := 0; tx < IconDim; tx++ {
+			sx0, sx1 := x0+tx*side/IconDim, x0+(tx+1)*side/IconDim
+			if sx1 == sx0 {
+				sx1 = sx0 + 1 // upscaling
+			}
+			// average premultiplied samples, then composite over white
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			white := 0xffff*n - a
+			p := (ty*IconDim + tx) * 3
+			rgb[p] = uint8((r + white) / n >> 8)
+			rgb[p+1] = uint8((g + white) / n >> 8)
+			rgb[p+2] = uint8((bl + white) / n >> 8)
+		}
+	}
+	return rgb
+}
+
+// Sum of Absolute Difference between 48x48 RGB images.
+func sad(rgb []byte, res [IconDim * IconDim * 3]byte) uint64 {
+	var sum uint64
+	for i, v := range rgb {
+		if v > res[i] {
+			sum += uint64(v - res[i])
+		} else {
+			sum += uint64(res[i] - v)
+		}
+	}
+	return sum
+}
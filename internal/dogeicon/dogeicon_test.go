@@ -0,0 +1,200 @@
+package dogeicon
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+var (
+	red  = color.NRGBA{R: 220, G: 30, B: 30, A: 255}
+	blue = color.NRGBA{R: 30, G: 30, B: 220, A: 255}
+)
+
+// centred draws a red square in the centre of a blue w x h image, so
+// cropping the centre square leaves only red.
+func centred(w, h int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	side := w
+	if h < side {
+		side = h
+	}
+	x0, y0 := (w-side)/2, (h-side)/2
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x >= x0 && x < x0+side && y >= y0 && y < y0+side {
+				img.SetNRGBA(x, y, red)
+			} else {
+				img.SetNRGBA(x, y, blue)
+			}
+		}
+	}
+	return img
+}
+
+// gradient is a w x h image with colour varying across it.
+func gradient(w, h int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func near(a, b color.NRGBA) bool {
+	diff := func(x, y uint8) int {
+		if x > y {
+			return int(x - y)
+		}
+		return int(y - x)
+	}
+	const tolerance = 40 // lossy compression
+	return diff(a.R, b.R) < tolerance && diff(a.G, b.G) < tolerance && diff(a.B, b.B) < tolerance
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name  string
+		img   image.Image
+		style int
+		want  int         // style byte (-1: either)
+		color color.NRGBA // expected colour of every decoded pixel (A=0: don't check)
+	}{
+		{"flat", gradient(48, 48), StyleFlat, StyleFlat, color.NRGBA{}},
+		{"linear", gradient(48, 48), StyleLinear, StyleLinear, color.NRGBA{}},
+		{"auto", gradient(48, 48), StyleAuto, -1, color.NRGBA{}},
+		{"square", centred(48, 48), StyleAuto, -1, red},
+		{"oversized", centred(400, 400), StyleAuto, -1, red},
+		{"wide", centred(300, 100), StyleFlat, StyleFlat, red},
+		{"tall", centred(50, 130), StyleFlat, StyleFlat, red},
+		{"tiny", centred(1, 1), StyleLinear, StyleLinear, red},
+		{"transparent", image.NewNRGBA(image.Rect(0, 0, 10, 10)), StyleFlat, StyleFlat, color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+	}
+	for _, test := range tests {
+		comp, err := Encode(test.img, test.style)
+		if err != nil {
+			t.Errorf("%v: Encode: %v", test.name, err)
+			continue
+		}
+		if len(comp) != IconSize || len(comp) > 1585 {
+			t.Errorf("%v: encoded %v bytes, want %v", test.name, len(comp), IconSize)
+			continue
+		}
+		if test.want >= 0 && int(comp[0]) != test.want {
+			t.Errorf("%v: style byte %v, want %v", test.name, comp[0], test.want)
+		}
+		img, err := Decode(comp)
+		if err != nil {
+			t.Errorf("%v: Decode: %v", test.name, err)
+			continue
+		}
+		if b := img.Bounds(); b.Dx() != IconDim || b.Dy() != IconDim {
+			t.Errorf("%v: decoded %vx%v, want %vx%v", test.name, b.Dx(), b.Dy(), IconDim, IconDim)
+		}
+		if test.color.A != 0 {
+			for _, p := range []image.Point{{0, 0}, {47, 0}, {0, 47}, {47, 47}, {24, 24}} {
+				if c := img.NRGBAAt(p.X, p.Y); !near(c, test.color) {
+					t.Errorf("%v: pixel %v is %v, want about %v (not cropped?)", test.name, p, c, test.color)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeAuto(t *testing.T) {
+	// auto chooses the style that decodes closest to the input
+	img := gradient(97, 61)
+	rgb := fit(img)
+	var errs [2]uint64
+	for _, style := range []int{StyleFlat, StyleLinear} {
+		comp, err := Encode(img, style)
+		if err != nil {
+			t.Fatalf("Encode(%v): %v", style, err)
+		}
+		errs[style] = sad(rgb, decodeRGB(t, comp))
+	}
+	comp, err := Encode(img, StyleAuto)
+	if err != nil {
+		t.Fatalf("Encode(auto): %v", err)
+	}
+	best := StyleFlat
+	if errs[StyleLinear] < errs[StyleFlat] {
+		best = StyleLinear
+	}
+	if int(comp[0]) != best {
+		t.Errorf("auto chose style %v, want %v (errors %v)", comp[0], best, errs)
+	}
+}
+
+func decodeRGB(t *testing.T, comp []byte) (res [IconDim * IconDim * 3]byte) {
+	img, err := Decode(comp)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	for p, s := 0, 0; s < len(res); p, s = p+4, s+3 {
+		copy(res[s:s+3], img.Pix[p:p+3])
+	}
+	return res
+}
+
+func TestEncodeErrors(t *testing.T) {
+	if _, err := Encode(gradient(8, 8), 2); err == nil {
+		t.Errorf("Encode accepted style 2")
+	}
+	if _, err := Encode(image.NewNRGBA(image.Rect(0, 0, 0, 10)), StyleAuto); err == nil {
+		t.Errorf("Encode accepted an empty image")
+	}
+	if _, err := Decode(nil); !errors.Is(err, ErrNoIcon) {
+		t.Errorf("Decode(nil): error %v, want ErrNoIcon", err)
+	}
+	if _, err := Decode(make([]byte, IconSize-1)); err == nil {
+		t.Errorf("Decode accepted a short icon")
+	}
+}
+
+func TestScale(t *testing.T) {
+	comp, err := Encode(centred(48, 48), StyleFlat)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	img, err := Decode(comp)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	tests := []struct {
+		size   int
+		filter string
+		ok     bool
+	}{
+		{48, Nearest, true},
+		{16, Nearest, true},
+		{96, "", true},
+		{100, Bilinear, true},
+		{768, Bilinear, true},
+		{15, Nearest, false},
+		{769, Nearest, false},
+		{64, "cubic", false},
+	}
+	for _, test := range tests {
+		res, err := Scale(img, test.size, test.filter)
+		if !test.ok {
+			if err == nil {
+				t.Errorf("Scale(%v, %q): no error", test.size, test.filter)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Scale(%v, %q): %v", test.size, test.filter, err)
+			continue
+		}
+		if b := res.Bounds(); b.Dx() != test.size || b.Dy() != test.size {
+			t.Errorf("Scale(%v, %q): got %vx%v", test.size, test.filter, b.Dx(), b.Dy())
+		}
+		if c := res.NRGBAAt(test.size/2, test.size/2); !near(c, red) {
+			t.Errorf("Scale(%v, %q): centre is %v, want about %v", test.size, test.filter, c, red)
+		}
+	}
+}
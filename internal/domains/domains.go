@@ -0,0 +1,163 @@
+package domains
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Domain verification links an identity to a name at a domain, like an
+// email address: alice@example.org is verified if
+// https://example.org/.well-known/dogeid.json?name=alice contains
+//
+//	{"names": {"alice": "<identity pubkey hex>"}}
+//
+// Redirects are not followed, so the domain itself must serve the document.
+
+const WellKnownPath = "/.well-known/dogeid.json"
+const MaxDocumentBytes = 64 * 1024
+const FetchTimeout = 10 * time.Second
+const CacheTime = 24 * time.Hour // re-verify claims older than this
+
+// Document is the /.well-known/dogeid.json document.
+type Document struct {
+	Names map[string]string `json:"names"` // name -> identity pubkey (hex)
+}
+
+// Verifier fetches and checks dogeid.json documents.
+type Verifier struct {
+	client *http.Client
+	url    func(domain string, name string) string
+}
+
+// NewVerifier creates a Verifier; a nil client uses a default client with
+// FetchTimeout, and a nil url fetches https://<domain>/.well-known/dogeid.json
+// (tests can substitute a stand-in server for both).
+func NewVerifier(client *http.Client, url func(domain string, name string) string) *Verifier {
+	if client == nil {
+		client = &http.Client{Timeout: FetchTimeout}
+	}
+	if url == nil {
+		url = DocumentURL
+	}
+	// never follow redirects
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &Verifier{client: &noRedirect, url: url}
+}
+
+// DocumentURL returns the dogeid.json URL for a name at a domain.
+func DocumentURL(domain string, name string) string {
+	return "https://" + domain + WellKnownPath + "?name=" + url.QueryEscape(name)
+}
+
+// ParseAddress splits and checks a "name@domain" address;
+// both parts are returned in lower case.
+func ParseAddress(address string) (name string, domain string, err error) {
+	name, domain, found := strings.Cut(strings.ToLower(strings.TrimSpace(address)), "@")
+	if !found {
+		return "", "", fmt.Errorf("invalid address: expecting name@domain (got %q)", address)
+	}
+	if err := CheckName(name); err != nil {
+		return "", "", err
+	}
+	host := domain
+	if h, port, err := net.SplitHostPort(domain); err == nil && port != "" {
+		host = h // allow a port (e.g. a stand-in server)
+	}
+	if host == "" || len(domain) > 253 || strings.ContainsAny(host, "/?#@[]\\ ") || (!strings.Contains(host, ".") && host != "localhost") {
+		return "", "", fmt.Errorf("invalid address: bad domain %q", domain)
+	}
+	return name, domain, nil
+}
+
+// CheckName checks the name part of an address: letters, digits, '.', '-' and '_'.
+func CheckName(name string) error {
+	if name == "" || len(name) > 64 {
+		return fmt.Errorf("invalid name: expecting 1-64 characters (got %q)", name)
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != '-' && r != '_' {
+			return fmt.Errorf("invalid name: only letters, digits, '.', '-' and '_' are allowed (got %q)", name)
+		}
+	}
+	return nil
+}
+
+// Handle derives an address name from a profile name ("" if none):
+// lower-case, with runs of other characters replaced by '-'.
+func Handle(profileName string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(profileName) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	if CheckName(b.String()) != nil {
+		return ""
+	}
+	return b.String()
+}
+
+// Verify fetches the domain's document and checks that the name maps to
+// the identity pubkey; returns a non-nil error if it does not.
+func (v *Verifier) Verify(ctx context.Context, address string, idenPub []byte) error {
+	name, domain, err := ParseAddress(address)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, FetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url(domain, name), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot fetch %v: %v", WellKnownPath, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("cannot fetch %v: %v", WellKnownPath, res.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, MaxDocumentBytes+1))
+	if err != nil {
+		return fmt.Errorf("cannot fetch %v: %v", WellKnownPath, err)
+	}
+	if len(body) > MaxDocumentBytes {
+		return fmt.Errorf("invalid %v: larger than %v bytes", WellKnownPath, MaxDocumentBytes)
+	}
+	var doc Document
+	err = json.Unmarshal(body, &doc)
+	if err != nil {
+		return fmt.Errorf("invalid %v: %v", WellKnownPath, err)
+	}
+	for key, pub := range doc.Names {
+		if strings.ToLower(key) == name {
+			if strings.EqualFold(pub, hex.EncodeToString(idenPub)) {
+				return nil
+			}
+			return fmt.Errorf("%v@%v belongs to another identity", name, domain)
+		}
+	}
+	return errors.New(name + "@" + domain + " is not listed by the domain")
+}
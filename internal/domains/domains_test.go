@@ -0,0 +1,73 @@
+package domains
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	pub := make([]byte, 32)
+	pub[31] = 1
+	other := make([]byte, 32)
+	other[31] = 2
+	doc := fmt.Sprintf(`{"names":{"alice":%q,"Bob":%q,"moved":%q,"big":%q}}`,
+		hex.EncodeToString(pub), hex.EncodeToString(other), hex.EncodeToString(pub), hex.EncodeToString(pub))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(WellKnownPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(doc))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		// the target would verify, so following it is an error
+		http.Redirect(w, r, WellKnownPath, http.StatusFound)
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		// a valid document padded past the limit
+		padding := strings.Repeat(" ", MaxDocumentBytes)
+		w.Write([]byte(doc[:len(doc)-1] + padding + "}"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// the stand-in server serves every domain; some names go to other paths
+	url := func(domain string, name string) string {
+		switch name {
+		case "moved":
+			return srv.URL + "/redirect"
+		case "big":
+			return srv.URL + "/big"
+		}
+		return srv.URL + WellKnownPath + "?name=" + name
+	}
+	v := NewVerifier(srv.Client(), url)
+
+	tests := []struct {
+		address string
+		want    string // error substring ("" if verified)
+	}{
+		{"alice@example.org", ""},
+		{"Alice@Example.org", ""},
+		{"bob@example.org", "belongs to another identity"},
+		{"carol@example.org", "is not listed by the domain"},
+		{"moved@example.org", "302"},
+		{"big@example.org", "larger than"},
+		{"alice", "invalid address"},
+	}
+	for _, test := range tests {
+		err := v.Verify(context.Background(), test.address, pub)
+		switch {
+		case test.want == "" && err != nil:
+			t.Errorf("Verify(%q): unexpected error: %v", test.address, err)
+		case test.want != "" && err == nil:
+			t.Errorf("Verify(%q): verified, want error containing %q", test.address, test.want)
+		case test.want != "" && !strings.Contains(err.Error(), test.want):
+			t.Errorf("Verify(%q): error %q, want error containing %q", test.address, err, test.want)
+		}
+	}
+}
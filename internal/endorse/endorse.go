@@ -0,0 +1,80 @@
+package endorse
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"code.dogecoin.org/gossip/codec"
+	"code.dogecoin.org/gossip/dnet"
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// Endorsements are signed by the endorsing identity and gossiped on the
+// Iden channel alongside identities. An endorsement stays active for
+// 30 days after signing; re-signing with Level 0 revokes it.
+
+var TagEndorse = dnet.NewTag("Endo")
+
+const EndorseMsgSize = 4 + 32 + 1
+
+// MaxClockSkew allows for peers whose clocks run ahead of ours.
+const MaxClockSkew = 10 * time.Minute
+
+// Endorsement levels.
+const (
+	LevelRevoked = 0 // withdraws an earlier endorsement
+	LevelVouch   = 1 // "I vouch for this person"
+)
+
+type EndorseMsg struct {
+	Time    dnet.DogeTime // time of signing
+	Subject []byte        // [32] endorsed identity pubkey
+	Level   uint8         // LevelVouch or LevelRevoked
+}
+
+func (msg *EndorseMsg) Encode() []byte {
+	if len(msg.Subject) != 32 {
+		panic("Invalid endorsement: subject must be 32 bytes")
+	}
+	e := codec.Encode(EndorseMsgSize)
+	e.UInt32le(uint32(msg.Time))
+	e.Bytes(msg.Subject)
+	e.UInt8(msg.Level)
+	return e.Result()
+}
+
+// DecodeEndorseMsg decodes and checks an endorsement payload.
+func DecodeEndorseMsg(payload []byte) (msg EndorseMsg, err error) {
+	if len(payload) < EndorseMsgSize {
+		return EndorseMsg{}, fmt.Errorf("invalid endorsement: expecting %v bytes (got %v)", EndorseMsgSize, len(payload))
+	}
+	d := codec.Decode(payload)
+	msg.Time = dnet.DogeTime(d.UInt32le())
+	msg.Subject = d.Bytes(32)
+	msg.Level = d.UInt8()
+	// future versions of the format can add new fields
+	// to the end of the format; check d.Has(n) bytes.
+	if msg.Level > LevelVouch {
+		return EndorseMsg{}, errors.New("invalid endorsement: unknown level")
+	}
+	return msg, nil
+}
+
+// CheckTime checks an endorsement is still active: signed within the
+// last 30 days (spec.ExpiryTime), and not in the future.
+func CheckTime(msg *EndorseMsg, now time.Time) error {
+	signed := msg.Time.Local()
+	if signed.After(now.Add(MaxClockSkew)) {
+		return fmt.Errorf("invalid endorsement: signed in the future (%v)", signed.UTC().Format(time.RFC3339))
+	}
+	if now.Sub(signed) > spec.ExpiryTime {
+		return fmt.Errorf("expired endorsement: signed %v", signed.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// SignEndorsement signs an encoded EndorseMsg with the identity key.
+func SignEndorsement(idenKey dnet.KeyPair, payload []byte) dnet.MessageView {
+	return dnet.MsgView(dnet.EncodeMessage(dnet.ChannelIdentity, TagEndorse, idenKey, payload))
+}
@@ -0,0 +1,63 @@
+package endorse
+
+import (
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// Trust scores are relative to a set of seeds (the local identity and
+// its pinned contacts), which have score 1. Each active endorsement passes
+// on TrustDecay of the endorser's score, and scores from several endorsers
+// combine as independent evidence: 1 - (1-a)(1-b)...
+
+const TrustDecay = 0.5
+const TrustMaxDepth = 4 // endorsement hops from a seed
+
+// Score is the trust score of one identity.
+type Score struct {
+	Value     float64 // [0, 1]
+	Depth     int     // endorsement hops from the nearest seed (0 for a seed)
+	Endorsers int     // number of active endorsements received
+}
+
+// Scores computes trust scores for every identity reachable from the seeds
+// (keyed by string(pubkey)); unreachable identities have no entry.
+func Scores(seeds [][]byte, endorsements []spec.Endorsement) map[string]Score {
+	endorsers := make(map[string][]string) // subject -> endorsers
+	for _, e := range endorsements {
+		if e.Level > LevelRevoked && string(e.Endorser) != string(e.Subject) {
+			endorsers[string(e.Subject)] = append(endorsers[string(e.Subject)], string(e.Endorser))
+		}
+	}
+	scores := make(map[string]Score, len(seeds))
+	for _, seed := range seeds {
+		scores[string(seed)] = Score{Value: 1, Depth: 0, Endorsers: len(endorsers[string(seed)])}
+	}
+	for depth := 1; depth <= TrustMaxDepth; depth++ {
+		next := make(map[string]Score, len(scores))
+		for id, s := range scores {
+			next[id] = s
+		}
+		for subject, from := range endorsers {
+			if s, ok := scores[subject]; ok && s.Depth == 0 {
+				continue // seeds are fully trusted
+			}
+			distrust := 1.0
+			for _, e := range from {
+				distrust *= 1 - scores[e].Value*TrustDecay
+			}
+			value := 1 - distrust
+			if value <= 0 {
+				continue // no endorser reached yet
+			}
+			s, seen := next[subject]
+			if !seen {
+				s.Depth = depth
+			}
+			s.Value = value
+			s.Endorsers = len(from)
+			next[subject] = s
+		}
+		scores = next
+	}
+	return scores
+}
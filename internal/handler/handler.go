@@ -2,15 +2,23 @@ package handler
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/hex"
+	"errors"
 	"io"
 	"log"
 	"net"
+	"sync"
 	"time"
 
 	"code.dogecoin.org/gossip/dnet"
 	"code.dogecoin.org/gossip/iden"
 	"code.dogecoin.org/governor"
+	"code.dogecoin.org/identity/internal/config"
+	"code.dogecoin.org/identity/internal/endorse"
+	"code.dogecoin.org/identity/internal/metrics"
+	"code.dogecoin.org/identity/internal/policy"
+	"code.dogecoin.org/identity/internal/profile"
 	"code.dogecoin.org/identity/internal/spec"
 )
 
@@ -23,7 +31,6 @@ import (
 // Prepares a set of identities to gossip to peers.
 
 const OneUnixDay = 86400
-const GossipIdentityInverval = 71 * time.Second // gossip a random identity to peers
 
 var ChanIden = dnet.NewTag("Iden")
 
@@ -32,16 +39,26 @@ type IdentityService struct {
 	_store          spec.Store
 	store           spec.StoreCtx
 	bind            spec.BindTo
+	sockMu          sync.Mutex // protects sock
 	sock            net.Conn
+	status          *spec.Status
 	idenKey         dnet.KeyPair
 	newIden         chan dnet.RawMessage // from announce.go
+	outgoing        chan dnet.RawMessage // from web.go: our own messages to send once
+	policy          *policy.Policy       // blocked identities and nodes
+	settings        *config.Settings     // reloadable settings (gossip interval)
 	announceChanges chan any
 	idenMsg         dnet.RawMessage
+	connects        int // connection attempts (for reconnect metrics)
 }
 
-func New(bind spec.BindTo, store spec.Store, idenKey dnet.KeyPair, newIden chan dnet.RawMessage, announceChanges chan any) governor.Service {
+func New(bind spec.BindTo, store spec.Store, idenKey dnet.KeyPair, newIden chan dnet.RawMessage, outgoing chan dnet.RawMessage, announceChanges chan any, status *spec.Status, policy *policy.Policy, settings *config.Settings) governor.Service {
 	return &IdentityService{
+		settings:        settings,
+		policy:          policy,
+		outgoing:        outgoing,
 		_store:          store,
+		status:          status,
 		bind:            bind,
 		idenKey:         idenKey,
 		newIden:         newIden,
@@ -53,6 +70,10 @@ func (s *IdentityService) Run() {
 	// bind store to context
 	s.store = s._store.WithCtx(s.Context)
 	// connect to dogenet service
+	if s.connects > 0 {
+		metrics.DogenetReconnects.Inc()
+	}
+	s.connects++
 	sock, err := net.Dial(s.bind.Network, s.bind.Address)
 	if err != nil {
 		log.Printf("[Iden] cannot connect: %v", err)
@@ -80,15 +101,35 @@ func (s *IdentityService) Run() {
 		// send the node's pubkey to the announce service
 		// so it can include the node key in the identity announcement
 		s.announceChanges <- spec.NodePubKeyMsg{PubKey: br.PubKey[:]}
+		s.status.SetNode(br.PubKey[:])
+		// our own node accepted our identity pubkey in the BindMessage,
+		// which is its claim of our identity (the other half of the proof)
+		err = s.store.SetNodeClaim(br.PubKey[:], s.idenKey.Pub[:], time.Now().Unix())
+		if err != nil {
+			log.Printf("[Iden] cannot store node claim: %v", err)
+		}
 	} else {
 		log.Printf("[Iden] invalid BindMessage reply: %v", err)
 		sock.Close()
 		return
 	}
 	log.Printf("[Iden] completed handshake.")
+	metrics.DogenetConnected.Set(1)
+	s.status.SetConnected(true)
+	defer func() {
+		metrics.DogenetConnected.Set(0)
+		s.status.SetConnected(false)
+	}()
 	// begin sending and listening for messages
+	s.sockMu.Lock()
 	s.sock = sock // for Stop()
+	s.sockMu.Unlock()
+	// stop this connection's senders when it closes, so they don't
+	// take messages meant for the next connection
+	done := make(chan struct{})
+	defer close(done)
 	go s.gossipMyIdentity(sock)
+	go s.gossipOutgoing(sock, done)
 	go s.gossipRandomIdentities(sock)
 	// read messages until reading fails
 	for !s.Stopping() {
@@ -105,6 +146,8 @@ func (s *IdentityService) Run() {
 		switch msg.Tag {
 		case iden.TagIdentity:
 			s.recvIden(msg)
+		case endorse.TagEndorse:
+			s.recvEndorse(msg)
 		default:
 			log.Printf("[Iden] unknown message: [%s][%s]", msg.Chan, msg.Tag)
 		}
@@ -112,14 +155,88 @@ func (s *IdentityService) Run() {
 }
 
 func (s *IdentityService) recvIden(msg dnet.Message) {
-	id := iden.DecodeIdentityMsg(msg.Payload)
+	metrics.IdentitiesReceived.Inc()
+	id, ok := profile.DecodeReceived(msg.Payload)
+	if !ok {
+		metrics.IdentitiesRejected.Inc()
+		log.Printf("[Iden] invalid identity from: %v", hex.EncodeToString(msg.PubKey))
+		return
+	}
+	if err := profile.CheckIdentity(&id); err != nil {
+		metrics.IdentitiesRejected.Inc()
+		log.Printf("[Iden] rejected identity from: %v: %v", hex.EncodeToString(msg.PubKey), err)
+		return
+	}
+	if why := s.policy.Blocked(msg.PubKey, id.Name, id.Nodes); why != "" {
+		metrics.IdentitiesBlocked.Inc()
+		log.Printf("[Iden] ignored identity from: %v: %v", hex.EncodeToString(msg.PubKey), why)
+		return
+	}
 	days := (id.Time.Local().Unix() - time.Now().Unix()) / OneUnixDay
 	log.Printf("[Iden] received identity: %v %v %v %v %v signed by: %v (%v days remain)", id.Name, id.Country, id.City, id.Lat, id.Long, hex.EncodeToString(msg.PubKey), days)
-	s.store.SetIdentity(msg.PubKey, msg.Payload, msg.Signature, id.Time.Local().Unix())
+	err := s.store.SetIdentity(msg.PubKey, msg.Payload, msg.Signature, id.Time.Local().Unix())
+	if err != nil {
+		metrics.IdentitiesRejected.Inc()
+		log.Printf("[Iden] cannot store identity: %v", err)
+		return
+	}
+	metrics.IdentitiesStored.Inc()
+}
+
+func (s *IdentityService) recvEndorse(msg dnet.Message) {
+	metrics.EndorsementsReceived.Inc()
+	end, err := endorse.DecodeEndorseMsg(msg.Payload)
+	if err == nil && bytes.Equal(end.Subject, msg.PubKey) {
+		err = errors.New("invalid endorsement: self-endorsement")
+	}
+	if err == nil {
+		// don't take back endorsements we (or our peers) have expired
+		err = endorse.CheckTime(&end, time.Now())
+	}
+	if err != nil {
+		metrics.EndorsementsRejected.Inc()
+		log.Printf("[Iden] rejected endorsement from: %v: %v", hex.EncodeToString(msg.PubKey), err)
+		return
+	}
+	// the endorser must be stored so the policy can check its name and nodes
+	// (blocked identities are never stored; others are gossiped again later)
+	_, _, _, err = s.store.GetIdentity(msg.PubKey)
+	if err != nil {
+		if !spec.IsNotFoundError(err) {
+			log.Printf("[Iden] cannot load endorser: %v", err)
+		}
+		metrics.EndorsementsRejected.Inc()
+		log.Printf("[Iden] ignored endorsement from unknown identity: %v", hex.EncodeToString(msg.PubKey))
+		return
+	}
+	if why := s.policy.SignerBlocked(s.store, msg.PubKey); why != "" {
+		metrics.EndorsementsRejected.Inc()
+		log.Printf("[Iden] ignored endorsement from: %v: %v", hex.EncodeToString(msg.PubKey), why)
+		return
+	}
+	log.Printf("[Iden] received endorsement: %v endorses %v (level %v)", hex.EncodeToString(msg.PubKey), hex.EncodeToString(end.Subject), end.Level)
+	err = s.store.SetEndorsement(spec.Endorsement{
+		Endorser: msg.PubKey,
+		Subject:  end.Subject,
+		Level:    int(end.Level),
+		Payload:  msg.Payload,
+		Sig:      msg.Signature,
+		Time:     end.Time.Local().Unix(),
+	})
+	if err != nil {
+		metrics.EndorsementsRejected.Inc()
+		log.Printf("[Iden] cannot store endorsement: %v", err)
+		return
+	}
+	metrics.EndorsementsStored.Inc()
 }
 
 func (s *IdentityService) Stop() {
-	s.sock.Close()
+	s.sockMu.Lock()
+	defer s.sockMu.Unlock()
+	if s.sock != nil { // nil if never connected
+		s.sock.Close()
+	}
 }
 
 // goroutine
@@ -136,36 +253,106 @@ func (s *IdentityService) gossipMyIdentity(sock net.Conn) {
 				sock.Close()
 				return
 			}
+			metrics.GossipSent.With("own").Inc()
+			s.status.SetGossiped(time.Now().Unix())
 			log.Printf("[Iden] sent message: %v %v", ChanIden, iden.TagIdentity)
 		}
 	}
 }
 
+// goroutine
+func (s *IdentityService) gossipOutgoing(sock net.Conn, done chan struct{}) {
+	for !s.Stopping() {
+		// send our own messages (e.g. endorsements) once
+		var rawMsg dnet.RawMessage
+		select {
+		case rawMsg = <-s.outgoing:
+		case <-done:
+			return
+		}
+		err := rawMsg.Send(sock)
+		if err != nil {
+			log.Printf("[Iden] cannot send to dogenet: %v", err)
+			sock.Close()
+			// send it on the next connection
+			select {
+			case s.outgoing <- rawMsg:
+			default:
+				log.Printf("[Iden] outgoing queue is full: message will be gossiped later")
+			}
+			return
+		}
+		metrics.GossipSent.With("outgoing").Inc()
+		log.Printf("[Iden] sent outgoing message")
+	}
+}
+
 // goroutine
 func (s *IdentityService) gossipRandomIdentities(sock net.Conn) {
 	for !s.Stopping() {
 		// wait for next turn
-		time.Sleep(GossipIdentityInverval)
+		time.Sleep(s.settings.GossipInterval())
 
-		// choose a random identity
-		pub, payload, sig, _, err := s.store.ChooseIdentity()
+		// expire old identities (advances once per day)
+		advanced, err := s.store.Trim()
 		if err != nil {
-			if spec.IsNotFoundError(err) {
-				log.Printf("[Iden]: no identities to gossip")
-			} else {
-				log.Printf("[Iden]: %v", err)
-			}
-			continue
+			log.Printf("[Iden]: %v", err)
+		} else if advanced {
+			log.Printf("[Iden] expired old identities")
 		}
 
-		// send the message to peers
-		msg := dnet.ReEncodeMessage(ChanIden, iden.TagIdentity, (*[32]byte)(pub), sig, payload)
-		err = msg.Send(sock)
-		if err != nil {
-			log.Printf("[Iden] cannot send to dogenet: %v", err)
+		// gossip a random identity and a random endorsement
+		if !s.gossipRandomIdentity(sock) || !s.gossipRandomEndorsement(sock) {
 			sock.Close()
 			return
 		}
-		log.Printf("[Iden] sent message: %v %v", ChanIden, iden.TagIdentity)
 	}
 }
+
+// gossipRandomIdentity sends a random stored identity (false if sending fails)
+func (s *IdentityService) gossipRandomIdentity(sock net.Conn) bool {
+	pub, payload, sig, _, err := s.store.ChooseIdentity()
+	if err != nil {
+		if spec.IsNotFoundError(err) {
+			log.Printf("[Iden]: no identities to gossip")
+		} else {
+			log.Printf("[Iden]: %v", err)
+		}
+		return true
+	}
+	if id, ok := profile.DecodeIdentity(payload); !ok || s.policy.Blocked(pub, id.Name, id.Nodes) != "" {
+		return true // don't gossip blocked identities
+	}
+	msg := dnet.ReEncodeMessage(ChanIden, iden.TagIdentity, (*[32]byte)(pub), sig, payload)
+	err = msg.Send(sock)
+	if err != nil {
+		log.Printf("[Iden] cannot send to dogenet: %v", err)
+		return false
+	}
+	metrics.GossipSent.With("random").Inc()
+	log.Printf("[Iden] sent message: %v %v", ChanIden, iden.TagIdentity)
+	return true
+}
+
+// gossipRandomEndorsement sends a random stored endorsement (false if sending fails)
+func (s *IdentityService) gossipRandomEndorsement(sock net.Conn) bool {
+	end, err := s.store.ChooseEndorsement()
+	if err != nil {
+		if !spec.IsNotFoundError(err) {
+			log.Printf("[Iden]: %v", err)
+		}
+		return true
+	}
+	if s.policy.SignerBlocked(s.store, end.Endorser) != "" {
+		return true // don't gossip endorsements by blocked identities
+	}
+	msg := dnet.ReEncodeMessage(ChanIden, endorse.TagEndorse, (*[32]byte)(end.Endorser), end.Sig, end.Payload)
+	err = msg.Send(sock)
+	if err != nil {
+		log.Printf("[Iden] cannot send to dogenet: %v", err)
+		return false
+	}
+	metrics.GossipSent.With("endorse").Inc()
+	log.Printf("[Iden] sent message: %v %v", ChanIden, endorse.TagEndorse)
+	return true
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/hex"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"code.dogecoin.org/gossip/dnet"
+	"code.dogecoin.org/gossip/node"
+	"code.dogecoin.org/governor"
+	"code.dogecoin.org/identity/internal/policy"
+	"code.dogecoin.org/identity/internal/profile"
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// Node Claims
+
+// Dogenet only delivers a channel's messages to handlers bound to it, so
+// this second handler connection binds the "Node" channel to receive node
+// address announcements. Each one is signed by the node and names the
+// identity it claims: the node's half of the two-way proof (see Nodes in
+// the identity announcement for the other half).
+
+type NodeClaimService struct {
+	governor.ServiceCtx
+	_store  spec.Store
+	store   spec.StoreCtx
+	bind    spec.BindTo
+	sockMu  sync.Mutex // protects sock
+	sock    net.Conn
+	idenKey dnet.KeyPair
+	policy  *policy.Policy // blocked nodes
+}
+
+func NewNodeClaims(bind spec.BindTo, store spec.Store, idenKey dnet.KeyPair, policy *policy.Policy) governor.Service {
+	return &NodeClaimService{
+		_store:  store,
+		bind:    bind,
+		idenKey: idenKey,
+		policy:  policy,
+	}
+}
+
+func (s *NodeClaimService) Run() {
+	// bind store to context
+	s.store = s._store.WithCtx(s.Context)
+	// connect to dogenet service
+	sock, err := net.Dial(s.bind.Network, s.bind.Address)
+	if err != nil {
+		log.Printf("[Node] cannot connect: %v", err)
+		return
+	}
+	// send channel bind request
+	bind := dnet.BindMessage{Version: 1, Chan: node.ChannelNode, PubKey: *s.idenKey.Pub}
+	_, err = sock.Write(bind.Encode())
+	if err != nil {
+		log.Printf("[Node] cannot send BindMessage: %v", err)
+		sock.Close()
+		return
+	}
+	// wait for the return bind request
+	reader := bufio.NewReader(sock)
+	br_buf := [dnet.BindMessageSize]byte{}
+	_, err = io.ReadAtLeast(reader, br_buf[:], len(br_buf))
+	if err != nil {
+		log.Printf("[Node] reading BindMessage reply: %v", err)
+		sock.Close()
+		return
+	}
+	if _, ok := dnet.DecodeBindMessage(br_buf[:]); !ok {
+		log.Printf("[Node] invalid BindMessage reply")
+		sock.Close()
+		return
+	}
+	log.Printf("[Node] listening for node claims.")
+	s.sockMu.Lock()
+	s.sock = sock // for Stop()
+	s.sockMu.Unlock()
+	// read messages until reading fails (ReadMessage checks signatures)
+	for !s.Stopping() {
+		msg, err := dnet.ReadMessage(reader)
+		if err != nil {
+			log.Printf("[Node] cannot receive from peer: %v", err)
+			sock.Close()
+			return
+		}
+		if msg.Chan == node.ChannelNode && msg.Tag == node.TagAddress {
+			s.recvNodeAddr(msg)
+		}
+	}
+}
+
+func (s *NodeClaimService) recvNodeAddr(msg dnet.Message) {
+	var addr node.AddressMsg
+	ok := profile.Decode(func() { addr = node.DecodeAddrMsg(msg.Payload) })
+	if !ok || !addr.IsValid() {
+		log.Printf("[Node] invalid node address from: %v", hex.EncodeToString(msg.PubKey))
+		return
+	}
+	if s.policy.NodeBlocked(msg.PubKey) {
+		return // don't record claims by blocked nodes
+	}
+	// record which identity the node claims (zeroes if none)
+	err := s.store.SetNodeClaim(msg.PubKey, addr.Owner, addr.Time.Local().Unix())
+	if err != nil {
+		log.Printf("[Node] cannot store node claim: %v", err)
+	}
+}
+
+func (s *NodeClaimService) Stop() {
+	s.sockMu.Lock()
+	defer s.sockMu.Unlock()
+	if s.sock != nil { // nil if never connected
+		s.sock.Close()
+	}
+}
@@ -0,0 +1,280 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Prometheus metrics in the text exposition format (version 0.0.4).
+// Metrics are package-level so every service can update them without
+// threading a registry through the constructors.
+
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Default histogram buckets (seconds), as used by the Prometheus clients.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Identity gossip.
+var (
+	IdentitiesReceived = NewCounter("identity_received_total", "Identity messages received from dogenet.")
+	IdentitiesStored   = NewCounter("identity_stored_total", "Received identities stored in the database.")
+	IdentitiesRejected = NewCounter("identity_rejected_total", "Received identities rejected (malformed or not stored).")
+	IdentitiesBlocked  = NewCounter("identity_blocked_total", "Received identities ignored by the local blocklist.")
+	IdentityCount      = NewGauge("identity_count", "Identities currently stored in the database.")
+	IdentitiesExpired  = NewCounter("identity_expired_total", "Identities expired by Trim.")
+	Trims              = NewCounter("identity_trim_total", "Trim runs that advanced the day counter.")
+	GossipSent         = NewCounterVec("identity_gossip_sent_total", "Messages sent to dogenet (own identity, random identity, endorsements).", "kind")
+)
+
+// Endorsement gossip.
+var (
+	EndorsementsReceived = NewCounter("identity_endorsement_received_total", "Endorsement messages received from dogenet.")
+	EndorsementsStored   = NewCounter("identity_endorsement_stored_total", "Received endorsements stored in the database.")
+	EndorsementsRejected = NewCounter("identity_endorsement_rejected_total", "Received endorsements rejected (malformed or not stored).")
+)
+
+// Connection to dogenet.
+var (
+	DogenetConnected  = NewGauge("identity_dogenet_connected", "1 if connected to dogenet (handshake completed), otherwise 0.")
+	DogenetReconnects = NewCounter("identity_dogenet_reconnects_total", "Connection attempts to dogenet after the first.")
+)
+
+// Our own announcement.
+var (
+	AnnounceTime     = NewGauge("identity_announce_signed_timestamp_seconds", "Unix time our current announcement was signed.")
+	AnnounceAge      = NewGaugeFunc("identity_announce_age_seconds", "Age of our current announcement.", announceAge)
+	AnnounceNextSign = NewGauge("identity_announce_next_sign_timestamp_seconds", "Unix time our announcement will next be re-signed.")
+)
+
+// Web API and database.
+var (
+	HTTPDuration = NewHistogramVec("identity_http_request_duration_seconds", "Web API request latency by route.", "route", DefaultBuckets)
+	TxnRetries   = NewCounterVec("identity_db_txn_retries_total", "SQLite transactions retried after a conflict.", "txn")
+)
+
+func announceAge() float64 {
+	signed := AnnounceTime.Get()
+	if signed == 0 {
+		return 0 // no announcement yet
+	}
+	return float64(time.Now().Unix()) - signed
+}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+var registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+func register(m metric) {
+	registry.mu.Lock()
+	registry.metrics = append(registry.metrics, m)
+	registry.mu.Unlock()
+}
+
+// WriteText writes all metrics in the Prometheus text format.
+func WriteText(w io.Writer) {
+	registry.mu.Lock()
+	list := registry.metrics
+	registry.mu.Unlock()
+	for _, m := range list {
+		m.write(w)
+	}
+}
+
+func header(w io.Writer, name string, help string, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// Counter is a monotonically increasing count.
+type Counter struct {
+	name string
+	help string
+	val  uint64
+}
+
+func NewCounter(name string, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	register(c)
+	return c
+}
+
+func (c *Counter) Inc()         { atomic.AddUint64(&c.val, 1) }
+func (c *Counter) Add(n uint64) { atomic.AddUint64(&c.val, n) }
+func (c *Counter) Get() uint64  { return atomic.LoadUint64(&c.val) }
+func (c *Counter) write(w io.Writer) {
+	header(w, c.name, c.help, "counter")
+	fmt.Fprintf(w, "%s %d\n", c.name, c.Get())
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	name string
+	help string
+	bits uint64 // float64 bits
+}
+
+func NewGauge(name string, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	register(g)
+	return g
+}
+
+func (g *Gauge) Set(v float64) { atomic.StoreUint64(&g.bits, math.Float64bits(v)) }
+func (g *Gauge) Get() float64  { return math.Float64frombits(atomic.LoadUint64(&g.bits)) }
+func (g *Gauge) write(w io.Writer) {
+	header(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.Get()))
+}
+
+// GaugeFunc is a gauge computed when the metrics are written.
+type GaugeFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+func NewGaugeFunc(name string, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, fn: fn}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	header(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.fn()))
+}
+
+// CounterVec is a set of counters partitioned by one label.
+type CounterVec struct {
+	name  string
+	help  string
+	label string
+	mu    sync.Mutex
+	vals  map[string]*Counter
+}
+
+func NewCounterVec(name string, help string, label string) *CounterVec {
+	v := &CounterVec{name: name, help: help, label: label, vals: make(map[string]*Counter)}
+	register(v)
+	return v
+}
+
+// With returns the counter for a label value.
+func (v *CounterVec) With(value string) *Counter {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c, ok := v.vals[value]
+	if !ok {
+		c = &Counter{name: v.name}
+		v.vals[value] = c
+	}
+	return c
+}
+
+func (v *CounterVec) write(w io.Writer) {
+	header(w, v.name, v.help, "counter")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, value := range sortedKeys(v.vals) {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", v.name, v.label, escapeLabel(value), v.vals[value].Get())
+	}
+}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64 // per bucket (not cumulative)
+	count   uint64
+	sum     float64
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i := sort.SearchFloat64s(h.buckets, v) // first bucket with le >= v
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+// HistogramVec is a set of histograms partitioned by one label.
+type HistogramVec struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64
+	mu      sync.Mutex
+	vals    map[string]*Histogram
+}
+
+func NewHistogramVec(name string, help string, label string, buckets []float64) *HistogramVec {
+	v := &HistogramVec{name: name, help: help, label: label, buckets: buckets, vals: make(map[string]*Histogram)}
+	register(v)
+	return v
+}
+
+// With returns the histogram for a label value.
+func (v *HistogramVec) With(value string) *Histogram {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	h, ok := v.vals[value]
+	if !ok {
+		h = &Histogram{buckets: v.buckets, counts: make([]uint64, len(v.buckets))}
+		v.vals[value] = h
+	}
+	return h
+}
+
+func (v *HistogramVec) write(w io.Writer) {
+	header(w, v.name, v.help, "histogram")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, value := range sortedKeys(v.vals) {
+		h := v.vals[value]
+		lbl := fmt.Sprintf("%s=\"%s\"", v.label, escapeLabel(value))
+		h.mu.Lock()
+		var cum uint64
+		for i, le := range h.buckets {
+			cum += h.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", v.name, lbl, formatFloat(le), cum)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", v.name, lbl, h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", v.name, lbl, formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", v.name, lbl, h.count)
+		h.mu.Unlock()
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
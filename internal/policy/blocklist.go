@@ -0,0 +1,47 @@
+package policy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// ParseBlocklist reads a shared blocklist file: one rule per line,
+// "<kind> <value>" where kind is identity, node or name, with an optional
+// "# reason" after hex values; blank lines and '#' comment lines are skipped.
+//
+//	# spam ring
+//	identity 437dd3787ef7ec7adc5418b997f6cc200c185dfae26b1aae6bc6359fb2370783 # giveaway scam
+//	node e09407d70f1e21a153dedab01538cd6cd267d59b8767c7c746cd63956703c829
+//	name *free doge*
+func ParseBlocklist(r io.Reader, source string) (rules []spec.PolicyRule, err error) {
+	scan := bufio.NewScanner(r)
+	line := 0
+	for scan.Scan() {
+		line++
+		text := strings.TrimSpace(scan.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		kind, value, _ := strings.Cut(text, " ")
+		reason := ""
+		if kind != KindName {
+			value, reason, _ = strings.Cut(value, "#")
+		}
+		if reason == "" {
+			reason = fmt.Sprintf("%v line %v", source, line)
+		}
+		rule, err := CheckRule(spec.PolicyRule{Kind: kind, Value: strings.TrimSpace(value), Reason: reason})
+		if err != nil {
+			return nil, fmt.Errorf("%v line %v: %v", source, line, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scan.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %v", source, err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,218 @@
+package policy
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"code.dogecoin.org/identity/internal/profile"
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// Policy is the local blocklist and allowlist, loaded from the store and
+// shared between the handler (receive and gossip) and the web API (lookups).
+//
+// An allowed identity is never blocked. Otherwise an identity is blocked
+// if its pubkey is blocked, it claims a blocked node, or its name matches
+// a blocked name pattern (unless it claims an allowed node).
+
+// Rule kinds.
+const (
+	KindIdentity = "identity" // identity pubkey (hex)
+	KindNode     = "node"     // node pubkey (hex)
+	KindName     = "name"     // name pattern: '*' matches anything
+)
+
+// Blocklist sources (rules added locally have no source).
+const (
+	SourceConfig = "config" // --blocklist or the config file's blocklist
+	SourceImport = "import" // POST /policy/import without ?source=
+)
+
+const MaxPatternBytes = 60
+
+type Policy struct {
+	mu           sync.RWMutex
+	blockedIdens map[string]string // pubkey -> reason
+	allowedIdens map[string]bool
+	blockedNodes map[string]string // pubkey -> reason
+	allowedNodes map[string]bool
+	names        []namePattern
+}
+
+type namePattern struct {
+	parts  []string // skeletons between '*'
+	reason string
+}
+
+func New() *Policy {
+	p := &Policy{}
+	p.Load(nil)
+	return p
+}
+
+// Load replaces all rules (after changing the rules in the store)
+func (p *Policy) Load(rules []spec.PolicyRule) {
+	blockedIdens := make(map[string]string)
+	allowedIdens := make(map[string]bool)
+	blockedNodes := make(map[string]string)
+	allowedNodes := make(map[string]bool)
+	var names []namePattern
+	for _, r := range rules {
+		reason := r.Reason
+		if reason == "" {
+			reason = fmt.Sprintf("%v %v", r.Kind, r.Value)
+		}
+		switch r.Kind {
+		case KindIdentity:
+			if r.Allow {
+				allowedIdens[keyOf(r.Value)] = true
+			} else {
+				blockedIdens[keyOf(r.Value)] = reason
+			}
+		case KindNode:
+			if r.Allow {
+				allowedNodes[keyOf(r.Value)] = true
+			} else {
+				blockedNodes[keyOf(r.Value)] = reason
+			}
+		case KindName:
+			names = append(names, namePattern{parts: patternParts(r.Value), reason: reason})
+		}
+	}
+	p.mu.Lock()
+	p.blockedIdens, p.allowedIdens = blockedIdens, allowedIdens
+	p.blockedNodes, p.allowedNodes = blockedNodes, allowedNodes
+	p.names = names
+	p.mu.Unlock()
+}
+
+// Blocked checks an identity against the policy; reason is "" if not blocked.
+func (p *Policy) Blocked(idenPub []byte, name string, nodes [][]byte) (reason string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.allowedIdens[string(idenPub)] {
+		return ""
+	}
+	if why, ok := p.blockedIdens[string(idenPub)]; ok {
+		return "blocked identity: " + why
+	}
+	allowedNode := false
+	for _, node := range nodes {
+		if why, ok := p.blockedNodes[string(node)]; ok {
+			return "blocked node: " + why
+		}
+		allowedNode = allowedNode || p.allowedNodes[string(node)]
+	}
+	if allowedNode || len(p.names) == 0 {
+		return ""
+	}
+	skel := profile.Skeleton(name)
+	for _, pat := range p.names {
+		if matchParts(pat.parts, skel) {
+			return "blocked name: " + pat.reason
+		}
+	}
+	return ""
+}
+
+// SignerBlocked checks the signer of a message that only carries its
+// pubkey (e.g. an endorsement) using its stored identity, if any.
+func (p *Policy) SignerBlocked(store spec.StoreCtx, idenPub []byte) (reason string) {
+	payload, _, _, err := store.GetIdentity(idenPub)
+	if err == nil {
+		if msg, ok := profile.DecodeIdentity(payload); ok {
+			return p.Blocked(idenPub, msg.Name, msg.Nodes)
+		}
+	}
+	return p.Blocked(idenPub, "", nil)
+}
+
+// NodeBlocked checks a node pubkey against the policy.
+func (p *Policy) NodeBlocked(nodePub []byte) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, blocked := p.blockedNodes[string(nodePub)]
+	return blocked && !p.allowedNodes[string(nodePub)]
+}
+
+// CheckRule validates a rule and returns it in canonical form.
+func CheckRule(r spec.PolicyRule) (spec.PolicyRule, error) {
+	switch r.Kind {
+	case KindIdentity, KindNode:
+		pub, err := hex.DecodeString(r.Value)
+		if err != nil || len(pub) != 32 {
+			return r, fmt.Errorf("invalid %v pubkey: expecting 32 bytes hex-encoded (got %v)", r.Kind, r.Value)
+		}
+		r.Value = hex.EncodeToString(pub)
+	case KindName:
+		if r.Allow {
+			return r, fmt.Errorf("invalid rule: name patterns can only block (allow the identity or node instead)")
+		}
+		pattern, err := profile.NormalizeText("name pattern", strings.TrimSpace(r.Value), MaxPatternBytes)
+		if err != nil {
+			return r, err
+		}
+		if strings.Join(patternParts(pattern), "") == "" {
+			return r, fmt.Errorf("invalid name pattern: matches every name: %q", r.Value)
+		}
+		r.Value = pattern
+	default:
+		return r, fmt.Errorf("invalid rule kind: expecting %v, %v or %v (got %q)", KindIdentity, KindNode, KindName, r.Kind)
+	}
+	reason, err := profile.NormalizeText("reason", strings.TrimSpace(r.Reason), MaxPatternBytes*2)
+	if err != nil {
+		return r, err
+	}
+	r.Reason = reason
+	return r, nil
+}
+
+// CheckSource validates a blocklist source name.
+func CheckSource(source string) (string, error) {
+	source, err := profile.NormalizeText("source", strings.TrimSpace(source), MaxPatternBytes)
+	if err != nil {
+		return source, err
+	}
+	if source == "" {
+		return source, fmt.Errorf("invalid source: expecting a name")
+	}
+	return source, nil
+}
+
+// keyOf converts a canonical hex pubkey to a map key.
+func keyOf(value string) string {
+	pub, _ := hex.DecodeString(value) // validated by CheckRule
+	return string(pub)
+}
+
+// patternParts splits a name pattern at '*' and reduces each part to its
+// confusables skeleton, so lookalike spellings match the same pattern.
+func patternParts(pattern string) []string {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = profile.Skeleton(part)
+	}
+	return parts
+}
+
+// matchParts matches a skeleton against pattern parts ("a*b" is [a b])
+func matchParts(parts []string, skel string) bool {
+	if len(parts) == 1 {
+		return skel == parts[0]
+	}
+	if !strings.HasPrefix(skel, parts[0]) {
+		return false
+	}
+	skel = skel[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(skel, part)
+		if i < 0 {
+			return false
+		}
+		skel = skel[i+len(part):]
+	}
+	return strings.HasSuffix(skel, last)
+}
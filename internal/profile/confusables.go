@@ -0,0 +1,58 @@
+package profile
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Skeleton reduces a name to a form where visually confusable names
+// (homoglyphs, accents, case, spacing and punctuation) compare equal,
+// in the spirit of the UTS #39 skeleton. Returns "" if nothing remains.
+func Skeleton(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(name) {
+		r = fold(r)
+		if unicode.Is(unicode.Mn, r) || unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsControl(r) || IsBidiControl(r) {
+			continue // accents, separators, punctuation
+		}
+		b.WriteRune(r)
+	}
+	// multi-letter lookalikes
+	return strings.NewReplacer("rn", "m", "vv", "w").Replace(b.String())
+}
+
+// fold maps a character to the lower-case Latin letter it looks like.
+// Capitals are looked up before and after lower-casing, because some only
+// look Latin as capitals (Greek 'Η' is 'h', but 'η' is not).
+func fold(r rune) rune {
+	if c, ok := confusables[r]; ok {
+		r = c
+	}
+	r = unicode.ToLower(r)
+	if c, ok := confusables[r]; ok {
+		r = c
+	}
+	return r
+}
+
+// Characters that look like a Latin letter (after NFKD). Lower-case entries
+// also cover their capitals; capitals are listed when they look different.
+var confusables = map[rune]rune{
+	// vertical strokes: I, i, l, 1 and | all look alike
+	'i': 'l', '1': 'l', '|': 'l', 'ı': 'l', 'ǀ': 'l',
+	// Latin
+	'ȷ': 'j', 'ſ': 'f', 'ƒ': 'f',
+	// digits
+	'0': 'o',
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'т': 't',
+	'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'l', 'ј': 'j', 'ԁ': 'd', 'һ': 'h', 'ӏ': 'l', 'ԛ': 'q', 'ԝ': 'w',
+	// Greek
+	'α': 'a', 'ι': 'l', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'υ': 'u', 'χ': 'x',
+	'Β': 'b', 'Ε': 'e', 'Ζ': 'z', 'Η': 'h', 'Ι': 'l', 'Κ': 'k', 'Μ': 'm', 'Ν': 'n', 'Ρ': 'p',
+	'Τ': 't', 'Υ': 'y', 'Χ': 'x',
+	// Armenian
+	'օ': 'o', 'ս': 'u', 'հ': 'h', 'ո': 'n',
+}
@@ -0,0 +1,43 @@
+package profile
+
+import "testing"
+
+func TestSkeleton(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"", ""},
+		{"  --  ", ""},
+		{"ivan", "lvan"},
+		{"Ivan", "lvan"},
+		{"IVAN", "lvan"},
+		{"lvan", "lvan"},
+		{"1van", "lvan"},
+		{"|van", "lvan"},
+		{"Іvan", "lvan"},  // Cyrillic capital I
+		{"іvan", "lvan"},  // Cyrillic small i
+		{"ıvan", "lvan"},  // dotless i
+		{"Ιvan", "lvan"},  // Greek capital iota
+		{"Ívan", "lvan"},  // accent
+		{"Ｉｖａｎ", "lvan"},  // fullwidth
+		{"I van", "lvan"}, // spacing
+		{"Such Doge", "suchdoge"},
+		{"Ѕuсh Dоgе", "suchdoge"}, // Cyrillic S, c, o, e
+		{"SUCH DOGE", "suchdoge"},
+		{"D0GE", "doge"},
+		{"ΗΕΝ", "hen"}, // Greek capitals that look Latin
+		{"modern", "modem"},
+		{"vvow", "wow"},
+	}
+	for _, test := range tests {
+		got := Skeleton(test.name)
+		if got != test.want {
+			t.Errorf("Skeleton(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+	// case variants must collide
+	if Skeleton("ivan") != Skeleton("IVAN") || Skeleton("іvan") != Skeleton("Іvan") {
+		t.Errorf("case variants do not compare equal")
+	}
+}
@@ -0,0 +1,39 @@
+package profile
+
+import (
+	"code.dogecoin.org/gossip/iden"
+	"golang.org/x/text/unicode/norm"
+)
+
+// The gossip decoders panic on truncated payloads, and payloads come from
+// peers (or were stored by older versions), so decode them with these.
+
+// Decode runs a gossip decoder; ok is false if the payload was malformed.
+func Decode(decode func()) (ok bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			ok = false // truncated payload
+		}
+	}()
+	decode()
+	return true
+}
+
+// DecodeIdentity decodes an identity payload, which may be malformed,
+// with its text normalised to NFC for display and comparison.
+func DecodeIdentity(payload []byte) (msg iden.IdentityMsg, ok bool) {
+	msg, ok = DecodeReceived(payload)
+	if ok {
+		msg.Name = norm.NFC.String(msg.Name)
+		msg.Bio = norm.NFC.String(msg.Bio)
+		msg.City = norm.NFC.String(msg.City)
+	}
+	return
+}
+
+// DecodeReceived decodes an identity payload as signed, to check it with
+// CheckIdentity.
+func DecodeReceived(payload []byte) (msg iden.IdentityMsg, ok bool) {
+	ok = Decode(func() { msg = iden.DecodeIdentityMsg(payload) })
+	return
+}
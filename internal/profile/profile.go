@@ -0,0 +1,136 @@
+package profile
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"code.dogecoin.org/gossip/iden"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Profile text validation shared by the web API (our own profile)
+// and the handler (identities received from peers).
+//
+// Limits are in bytes because that is what the wire format limits
+// (see iden.IdentityMsg.IsValid). Our own text is normalised to NFC first;
+// received text may be in any form (other implementations may send NFD,
+// e.g. from macOS input) and is normalised for display (see DecodeIdentity).
+
+const MaxNameBytes = 30
+const MaxBioBytes = 120
+const MaxCityBytes = 30
+
+// Lat and Long are stored in tenths of a degree.
+const MaxLat = 900
+const MaxLong = 1800
+
+// NormalizeText checks a text field and returns it in NFC form.
+func NormalizeText(field string, text string, maxBytes int) (string, error) {
+	if !utf8.ValidString(text) {
+		return "", fmt.Errorf("invalid %v: not valid UTF-8", field)
+	}
+	text = norm.NFC.String(text)
+	if err := checkRunes(field, text, false); err != nil {
+		return "", err
+	}
+	if len(text) > maxBytes {
+		return "", fmt.Errorf("invalid %v: %v characters encode to %v bytes (max %v bytes)", field, utf8.RuneCountInString(text), len(text), maxBytes)
+	}
+	return text, nil
+}
+
+// CheckText checks a received text field as signed (in any normalisation form).
+func CheckText(field string, text string, maxBytes int) error {
+	if !utf8.ValidString(text) {
+		return fmt.Errorf("invalid %v: not valid UTF-8", field)
+	}
+	if err := checkRunes(field, text, false); err != nil {
+		return err
+	}
+	if len(text) > maxBytes {
+		return fmt.Errorf("invalid %v: %v bytes (max %v bytes)", field, len(text), maxBytes)
+	}
+	return nil
+}
+
+// NormalizeNote is NormalizeText for multi-line text, which may contain
+// line breaks and tabs (CRLF becomes LF).
+func NormalizeNote(field string, text string, maxBytes int) (string, error) {
+	if !utf8.ValidString(text) {
+		return "", fmt.Errorf("invalid %v: not valid UTF-8", field)
+	}
+	text = norm.NFC.String(strings.ReplaceAll(text, "\r\n", "\n"))
+	if err := checkRunes(field, text, true); err != nil {
+		return "", err
+	}
+	if len(text) > maxBytes {
+		return "", fmt.Errorf("invalid %v: %v characters encode to %v bytes (max %v bytes)", field, utf8.RuneCountInString(text), len(text), maxBytes)
+	}
+	return text, nil
+}
+
+func checkRunes(field string, text string, multiLine bool) error {
+	for _, r := range text {
+		if multiLine && (r == '\n' || r == '\t') {
+			continue
+		}
+		if unicode.IsControl(r) {
+			return fmt.Errorf("invalid %v: contains control character %U", field, r)
+		}
+		if IsBidiControl(r) {
+			return fmt.Errorf("invalid %v: contains bidirectional control character %U", field, r)
+		}
+	}
+	return nil
+}
+
+// IsBidiControl is true for characters that change the display order of text.
+func IsBidiControl(r rune) bool {
+	switch {
+	case r == '\u061C': // ARABIC LETTER MARK
+		return true
+	case r == '\u200E' || r == '\u200F': // LRM, RLM
+		return true
+	case r >= '\u202A' && r <= '\u202E': // LRE, RLE, PDF, LRO, RLO
+		return true
+	case r >= '\u2066' && r <= '\u2069': // LRI, RLI, FSI, PDI
+		return true
+	}
+	return false
+}
+
+// CheckIdentity validates an IdentityMsg received from a peer
+// (decoded with DecodeReceived, so the limits apply to the signed text).
+func CheckIdentity(msg *iden.IdentityMsg) error {
+	if err := CheckText("name", msg.Name, MaxNameBytes); err != nil {
+		return err
+	}
+	if err := CheckText("bio", msg.Bio, MaxBioBytes); err != nil {
+		return err
+	}
+	if err := CheckText("city", msg.City, MaxCityBytes); err != nil {
+		return err
+	}
+	if !IsCountry(msg.Country) && !isEmptyCountry(msg.Country) {
+		return fmt.Errorf("invalid country: not an ISO 3166-1 alpha-2 code: %q", msg.Country)
+	}
+	if msg.Lat < -MaxLat || msg.Lat > MaxLat {
+		return fmt.Errorf("invalid latitude: out of range (got %v)", msg.Lat)
+	}
+	if msg.Long < -MaxLong || msg.Long > MaxLong {
+		return fmt.Errorf("invalid longitude: out of range (got %v)", msg.Long)
+	}
+	for _, pub := range msg.Nodes {
+		if len(pub) != 32 {
+			return fmt.Errorf("invalid node pubkey: expecting 32 bytes (got %v)", len(pub))
+		}
+	}
+	return nil
+}
+
+// isEmptyCountry: a zero-padded country decodes as "\x00" (see codec.PadString)
+func isEmptyCountry(country string) bool {
+	return country == "" || country == "\x00"
+}
@@ -0,0 +1,40 @@
+package profile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeNote(t *testing.T) {
+	tests := []struct {
+		note string
+		want string
+		err  string // error substring ("" if valid)
+	}{
+		{"", "", ""},
+		{"met at the meetup", "met at the meetup", ""},
+		{"line one\nline two", "line one\nline two", ""},
+		{"line one\r\nline two", "line one\nline two", ""},
+		{"name:\tDoge", "name:\tDoge", ""},
+		{"é", "é", ""}, // NFD to NFC
+		{"bell\a", "", "control character U+0007"},
+		{"lone\rreturn", "", "control character U+000D"},
+		{"‮evil", "", "bidirectional control character U+202E"},
+		{"\xff", "", "not valid UTF-8"},
+		{strings.Repeat("a", 41), "", "max 40 bytes"},
+	}
+	for _, test := range tests {
+		got, err := NormalizeNote("note", test.note, 40)
+		if test.err == "" {
+			if err != nil || got != test.want {
+				t.Errorf("NormalizeNote(%q) = %q, %v; want %q", test.note, got, err, test.want)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("NormalizeNote(%q): error %v, want error containing %q", test.note, err, test.err)
+		}
+	}
+	// names still reject line breaks
+	if _, err := NormalizeText("name", "two\nlines", MaxNameBytes); err == nil {
+		t.Errorf("NormalizeText accepted a line break")
+	}
+}
@@ -0,0 +1,74 @@
+package sign
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"code.dogecoin.org/gossip/dnet"
+	"github.com/dogeorg/doge"
+)
+
+// Signed messages prove control of an identity (e.g. login challenges).
+//
+// The identity key also signs gossip payloads, so the signed bytes are
+// domain-separated: MessagePrefix, then the domain (the app or site that
+// asked for the signature), a newline, then the message.
+//
+// MessagePrefix is 37 bytes so a signed message cannot decode as a valid
+// IdentityMsg (byte 4 is a 99-byte name length; the limit is 30) or as an
+// EndorseMsg (byte 36 is the level, '\n' is not a valid level).
+const MessagePrefix = "Dogecoin Identity Signed Message v1:\n"
+
+const MaxDomainBytes = 100
+const MaxMessageBytes = 8192
+
+// Payload returns the domain-separated bytes to sign.
+func Payload(domain string, message string) ([]byte, error) {
+	if domain == "" {
+		return nil, errors.New("invalid domain: required (the app or site asking for the signature)")
+	}
+	if len(domain) > MaxDomainBytes {
+		return nil, fmt.Errorf("invalid domain: %v bytes (max %v bytes)", len(domain), MaxDomainBytes)
+	}
+	for _, r := range domain {
+		if r <= ' ' || r > '~' {
+			return nil, fmt.Errorf("invalid domain: expecting printable ASCII without spaces (got %q)", domain)
+		}
+	}
+	if !utf8.ValidString(message) {
+		return nil, errors.New("invalid message: not valid UTF-8")
+	}
+	if len(message) > MaxMessageBytes {
+		return nil, fmt.Errorf("invalid message: %v bytes (max %v bytes)", len(message), MaxMessageBytes)
+	}
+	return []byte(MessagePrefix + domain + "\n" + message), nil
+}
+
+// Sign signs a message with the identity key; returns the 64-byte signature.
+func Sign(idenKey dnet.KeyPair, domain string, message string) ([]byte, error) {
+	payload, err := Payload(domain, message)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := doge.SignMessage(idenKey.Priv, payload)
+	if err != nil {
+		return nil, err
+	}
+	return sig[:], nil
+}
+
+// Verify checks a signature by an identity pubkey (all arguments untrusted).
+func Verify(idenPub []byte, domain string, message string, sig []byte) (bool, error) {
+	if len(idenPub) != 32 {
+		return false, fmt.Errorf("invalid identity pubkey: expecting 32 bytes (got %v)", len(idenPub))
+	}
+	if len(sig) != 64 {
+		return false, fmt.Errorf("invalid signature: expecting 64 bytes (got %v)", len(sig))
+	}
+	payload, err := Payload(domain, message)
+	if err != nil {
+		return false, err
+	}
+	return doge.VerifyMessage((*[32]byte)(idenPub), payload, (*[64]byte)(sig)), nil
+}
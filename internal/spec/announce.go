@@ -3,3 +3,7 @@ package spec
 type NodePubKeyMsg struct {
 	PubKey []byte
 }
+
+// NodesChangedMsg tells Announce to reload the profile's node list from
+// the store (re-signing the announcement if it changed)
+type NodesChangedMsg struct{}
@@ -0,0 +1,81 @@
+package spec
+
+import "sync"
+
+// Status is the service state shared between the handler, announce and web
+// services; the web API reports it on /healthz, /readyz and /status.
+type Status struct {
+	mu             sync.Mutex
+	identity       []byte // our identity pubkey
+	connected      bool   // handler completed the dogenet handshake
+	node           []byte // pubkey of our dogenet node (from the handshake)
+	announceSigned int64  // unix time our announcement was signed (0 if none)
+	announceExpiry int64  // unix time our announcement expires
+	pending        string // why the announcement is waiting to be (re-)signed ("" if not)
+	lastGossiped   int64  // unix time our announcement was last sent to dogenet
+}
+
+// StatusSnapshot is a copy of the Status at one moment.
+type StatusSnapshot struct {
+	Identity       []byte
+	Connected      bool
+	Node           []byte
+	AnnounceSigned int64
+	AnnounceExpiry int64
+	Pending        string
+	LastGossiped   int64
+}
+
+func NewStatus(identity []byte) *Status {
+	return &Status{identity: identity}
+}
+
+// SetConnected records whether the dogenet handshake is complete.
+func (s *Status) SetConnected(connected bool) {
+	s.mu.Lock()
+	s.connected = connected
+	s.mu.Unlock()
+}
+
+// SetNode records the pubkey of our dogenet node.
+func (s *Status) SetNode(node []byte) {
+	s.mu.Lock()
+	s.node = node
+	s.mu.Unlock()
+}
+
+// SetAnnounce records our current signed announcement.
+func (s *Status) SetAnnounce(signed int64, expires int64) {
+	s.mu.Lock()
+	s.announceSigned = signed
+	s.announceExpiry = expires
+	s.mu.Unlock()
+}
+
+// SetPending records why the announcement is waiting to be (re-)signed.
+func (s *Status) SetPending(reason string) {
+	s.mu.Lock()
+	s.pending = reason
+	s.mu.Unlock()
+}
+
+// SetGossiped records when our announcement was sent to dogenet.
+func (s *Status) SetGossiped(time int64) {
+	s.mu.Lock()
+	s.lastGossiped = time
+	s.mu.Unlock()
+}
+
+func (s *Status) Get() StatusSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return StatusSnapshot{
+		Identity:       s.identity,
+		Connected:      s.connected,
+		Node:           s.node,
+		AnnounceSigned: s.announceSigned,
+		AnnounceExpiry: s.announceExpiry,
+		Pending:        s.pending,
+		LastGossiped:   s.lastGossiped,
+	}
+}
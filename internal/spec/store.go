@@ -6,12 +6,106 @@ import (
 	"time"
 )
 
-// Keep identities for 30 days before expiry
+// Keep identities for 30 days before expiry.
+//
+// This is part of the protocol rather than a setting: every node expires an
+// identity 30 days after it was signed, and identities are re-signed well
+// within that (see config.File AnnounceInterval). A node that kept them
+// longer would re-gossip identities its peers have already dropped.
 const ExpiryTime = time.Duration(30 * 24 * time.Hour)
 
 // Store is the top-level interface (e.g. SQLiteStore)
 type Store interface {
 	WithCtx(ctx context.Context) StoreCtx
+	// Changed returns a channel that is closed when the next Change is stored.
+	Changed() <-chan struct{}
+}
+
+// Identity is a stored identity record.
+type Identity struct {
+	PubKey  []byte // identity pubkey
+	Payload []byte // encoded IdentityMsg
+	Sig     []byte // signature of the payload
+	Time    int64  // unix time when signed
+}
+
+// NearbyIdentity is an Identity found by a radius query.
+type NearbyIdentity struct {
+	Identity
+	Distance float64 // great-circle distance from the query point (km)
+}
+
+// ProfileNode is a node pubkey included in our announcement.
+type ProfileNode struct {
+	PubKey []byte // node pubkey
+	Time   int64  // unix time the node was last seen (bound to our identity)
+}
+
+// Endorsement is a stored endorsement of one identity by another.
+type Endorsement struct {
+	Endorser []byte // endorsing identity pubkey (signer)
+	Subject  []byte // endorsed identity pubkey
+	Level    int    // 0 = revoked, 1 = vouch
+	Payload  []byte // signed EndorseMsg payload
+	Sig      []byte // signature by the endorser
+	Time     int64  // unix time of signing
+}
+
+// Contact is a pinned identity we know by name.
+type Contact struct {
+	PubKey []byte // identity pubkey
+	Name   string // name we know them by
+	Time   int64  // unix time pinned
+}
+
+// Petname is our private local name and note for an identity (never gossiped).
+type Petname struct {
+	PubKey  []byte // identity pubkey
+	Petname string // our name for them
+	Note    string // free-text note
+	Time    int64  // unix time last edited
+}
+
+// PolicyRule blocks or allows an identity, a node or a name pattern.
+type PolicyRule struct {
+	Kind   string // "identity", "node" or "name"
+	Value  string // pubkey (hex) or name pattern
+	Allow  bool   // allow instead of block
+	Reason string // why (shown when an identity is blocked)
+	Source string // blocklist the rule was imported from ("" if added locally)
+	Time   int64  // unix time added
+}
+
+// DomainClaim links an identity to a name@domain address (see internal/domains).
+type DomainClaim struct {
+	Address  string // name@domain (lower case)
+	PubKey   []byte // identity pubkey
+	Verified bool   // the domain's dogeid.json lists the identity
+	Checked  int64  // unix time last checked
+	Error    string // why verification failed ("" if verified)
+}
+
+// Lookalike is an identity whose name looks like another identity's name.
+type Lookalike struct {
+	PubKey []byte // pubkey of the pinned contact or older identity
+	Name   string // its name
+	Pinned bool   // true for a pinned contact, false for an older identity
+}
+
+// Kinds of Change in the identity change feed.
+const (
+	ChangeNew     = "new"     // first time we have seen the identity
+	ChangeUpdated = "updated" // identity was re-signed with a newer time
+	ChangeExpired = "expired" // identity was removed by Trim
+	ChangeRevoked = "revoked" // identity was re-signed without any nodes
+)
+
+// Change is an entry in the identity change feed.
+type Change struct {
+	ID     int64  // increasing event id (resume after this id)
+	Kind   string // one of ChangeNew, ChangeUpdated, ChangeExpired, ChangeRevoked
+	PubKey []byte // identity pubkey
+	Time   int64  // unix time when the change was stored
 }
 
 // StoreCtx is a Store bound to a cancellable Context
@@ -20,6 +114,20 @@ type StoreCtx interface {
 	SetIdentity(pub []byte, payload []byte, sig []byte, time int64) error
 	// Get stored identity by pubkey.
 	GetIdentity(pub []byte) (payload []byte, sig []byte, time int64, err error)
+	// Get the unix time (start of day) when Trim will expire a stored identity.
+	GetIdentityExpiry(pub []byte) (expires int64, err error)
+	// List stored identities in pubkey order, after the given pubkey (nil for the first page)
+	ListIdentities(after []byte, limit int) (list []Identity, err error)
+	// Check that the database can be read.
+	Ping() error
+	// Check that the database can be written (takes the write lock, changes nothing)
+	CheckWritable() error
+	// Count stored identities.
+	CountIdentities() (count int64, err error)
+	// Find identities located in a bounding box (WGS84 degrees; minLon > maxLon crosses the antimeridian)
+	FindIdentitiesInBox(minLat, minLon, maxLat, maxLon float64, limit int) (list []Identity, err error)
+	// Find identities located within a radius (km) of a point, nearest first.
+	FindIdentitiesNear(lat, lon float64, km float64, limit int) (list []NearbyIdentity, err error)
 	// Get a random stored identity (to gossip)
 	ChooseIdentity() (pubkey []byte, payload []byte, sig []byte, time int64, err error)
 	// Get the stored announcement, if any.
@@ -28,8 +136,69 @@ type StoreCtx interface {
 	GetProfile() (profile Profile, err error)
 	SetProfile(profile Profile) error
 	GetProfileNodes() (nodeList [][]byte, err error)
+	// Add a node to the profile, or update the time it was last seen.
 	AddProfileNode(pubkey []byte) error
+	// List profile nodes with the time each was last seen, most recent first.
+	ListProfileNodes() (list []ProfileNode, err error)
+	// Remove a node from the profile (ErrNotFound if not present)
+	RemoveProfileNode(pubkey []byte) error
+	// Remove profile nodes last seen before the given unix time, except keep.
+	ExpireProfileNodes(before int64, keep []byte) (expired [][]byte, err error)
+	// Pin a contact: an identity we know by name (names that look alike are flagged)
+	PinContact(pubkey []byte, name string) error
+	// Unpin a contact (ErrNotFound if not pinned)
+	UnpinContact(pubkey []byte) error
+	// List pinned contacts in name order.
+	ListContacts() (list []Contact, err error)
+	// Find a pinned contact or older identity whose name looks like this identity's name (nil if none)
+	FindLookalike(pubkey []byte) (found *Lookalike, err error)
+	// Find identities whose name contains the query (compared by skeleton), oldest first.
+	FindIdentitiesByName(query string, limit int) (list []Identity, err error)
+	// Set our private petname and note for an identity.
+	SetPetname(pubkey []byte, petname string, note string) error
+	// Get our petname and note for an identity (ErrNotFound if none)
+	GetPetname(pubkey []byte) (p Petname, err error)
+	// Remove our petname and note for an identity (ErrNotFound if none)
+	RemovePetname(pubkey []byte) error
+	// List petnames in petname order.
+	ListPetnames() (list []Petname, err error)
+	// Add or replace local policy rules (keyed by kind and value)
+	SetPolicyRules(rules []PolicyRule) error
+	// Replace the rules imported from a blocklist source (local rules are kept)
+	ImportPolicyRules(source string, rules []PolicyRule) error
+	// Remove a policy rule (ErrNotFound if none)
+	RemovePolicyRule(kind string, value string) error
+	// List policy rules by kind and value.
+	ListPolicyRules() (list []PolicyRule, err error)
+	// Add or update a domain claim (keyed by address)
+	SetDomainClaim(c DomainClaim) error
+	// Get the domain claims of an identity (verified first)
+	GetDomainClaims(pubkey []byte) (list []DomainClaim, err error)
+	// Remove a domain claim (ErrNotFound if none)
+	RemoveDomainClaim(address string) error
+	// List domain claims by address.
+	ListDomainClaims() (list []DomainClaim, err error)
+	// Insert or Update an Endorsement (only update if time is newer!)
+	SetEndorsement(e Endorsement) error
+	// Get active and revoked endorsements of an identity.
+	GetEndorsementsOf(subject []byte) (list []Endorsement, err error)
+	// Get active and revoked endorsements made by an identity.
+	GetEndorsementsBy(endorser []byte) (list []Endorsement, err error)
+	// Get all active endorsements (for trust scores)
+	ListEndorsements() (list []Endorsement, err error)
+	// Get a random stored endorsement (to gossip)
+	ChooseEndorsement() (e Endorsement, err error)
+	// Record the identity claimed by a node (only update if time is newer!)
+	SetNodeClaim(node []byte, identity []byte, time int64) error
+	// Get the identity claimed by a node.
+	GetNodeClaim(node []byte) (identity []byte, time int64, err error)
 	Trim() (advanced bool, err error)
+	// Get changes in the change feed after the given id (oldest first)
+	GetChanges(after int64, limit int) (changes []Change, err error)
+	// Get the most recent change to an identity (ErrNotFound if none in the feed)
+	LastChangeTo(pubkey []byte) (c Change, err error)
+	// Get the id of the most recent change (zero if none)
+	LatestChange() (id int64, err error)
 }
 
 var ErrNotFound = errors.New("not found")
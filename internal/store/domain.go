@@ -0,0 +1,67 @@
+package store
+
+import (
+	"database/sql"
+
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+func (s SQLiteStoreCtx) SetDomainClaim(c spec.DomainClaim) error {
+	return s.doTxn("SetDomainClaim", func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO domain (address,pubkey,verified,checked,error) VALUES (?,?,?,?,?) ON CONFLICT(address) DO UPDATE SET pubkey=excluded.pubkey,verified=excluded.verified,checked=excluded.checked,error=excluded.error", c.Address, c.PubKey, c.Verified, c.Checked, c.Error)
+		return err
+	})
+}
+
+func (s SQLiteStoreCtx) GetDomainClaims(pubkey []byte) (list []spec.DomainClaim, err error) {
+	err = s.doTxn("GetDomainClaims", func(tx *sql.Tx) error {
+		list, err = queryDomainClaims(tx, "WHERE pubkey=? ORDER BY verified DESC, address", pubkey)
+		return err
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) RemoveDomainClaim(address string) error {
+	return s.doTxn("RemoveDomainClaim", func(tx *sql.Tx) error {
+		res, err := tx.Exec("DELETE FROM domain WHERE address=?", address)
+		if err != nil {
+			return err
+		}
+		num, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if num == 0 {
+			return spec.ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) ListDomainClaims() (list []spec.DomainClaim, err error) {
+	err = s.doTxn("ListDomainClaims", func(tx *sql.Tx) error {
+		list, err = queryDomainClaims(tx, "ORDER BY address")
+		return err
+	})
+	return
+}
+
+func queryDomainClaims(tx *sql.Tx, where string, args ...any) (list []spec.DomainClaim, err error) {
+	rows, err := tx.Query("SELECT address,pubkey,verified,checked,error FROM domain "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c spec.DomainClaim
+		err = rows.Scan(&c.Address, &c.PubKey, &c.Verified, &c.Checked, &c.Error)
+		if err != nil {
+			return nil, dbErr(err, "queryDomainClaims: scanning row")
+		}
+		list = append(list, c)
+	}
+	if err = rows.Err(); err != nil { // docs say this check is required!
+		return nil, dbErr(err, "queryDomainClaims: query")
+	}
+	return list, nil
+}
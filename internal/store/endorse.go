@@ -0,0 +1,93 @@
+package store
+
+import (
+	"database/sql"
+
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+func (s SQLiteStoreCtx) SetEndorsement(e spec.Endorsement) error {
+	return s.doTxn("SetEndorsement", func(tx *sql.Tx) error {
+		// endorsement expires 30 days after signing (counted from the last
+		// day Trim advanced the day counter, like the counter itself)
+		signedDay := e.Time / SecondsPerDay
+		res, err := tx.Exec("UPDATE endorse SET level=?,payload=?,sig=?,time=?,dayc=(SELECT dayc+30-(last-?) FROM config LIMIT 1) WHERE endorser=? AND subject=? AND time<?", e.Level, e.Payload, e.Sig, e.Time, signedDay, e.Endorser, e.Subject, e.Time)
+		if err != nil {
+			return err
+		}
+		num, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if num == 0 {
+			_, err = tx.Exec("INSERT INTO endorse (endorser,subject,level,payload,sig,time,dayc) VALUES (?,?,?,?,?,?,(SELECT dayc+30-(last-?) FROM config LIMIT 1))", e.Endorser, e.Subject, e.Level, e.Payload, e.Sig, e.Time, signedDay)
+			if IsConstraint(err) {
+				return nil // key conflict: means the new time was earlier than the stored record.
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) GetEndorsementsOf(subject []byte) (list []spec.Endorsement, err error) {
+	err = s.doTxn("GetEndorsementsOf", func(tx *sql.Tx) error {
+		found, err := queryEndorsements(tx, "WHERE subject=? ORDER BY time DESC", subject)
+		list = found
+		return err
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) GetEndorsementsBy(endorser []byte) (list []spec.Endorsement, err error) {
+	err = s.doTxn("GetEndorsementsBy", func(tx *sql.Tx) error {
+		found, err := queryEndorsements(tx, "WHERE endorser=? ORDER BY time DESC", endorser)
+		list = found
+		return err
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) ListEndorsements() (list []spec.Endorsement, err error) {
+	err = s.doTxn("ListEndorsements", func(tx *sql.Tx) error {
+		found, err := queryEndorsements(tx, "WHERE level>0")
+		list = found
+		return err
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) ChooseEndorsement() (e spec.Endorsement, err error) {
+	err = s.doTxn("ChooseEndorsement", func(tx *sql.Tx) error {
+		list, err := queryEndorsements(tx, "WHERE oid IN (SELECT oid FROM endorse ORDER BY RANDOM() LIMIT 1)")
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			return spec.ErrNotFound
+		}
+		e = list[0]
+		return nil
+	})
+	return
+}
+
+func queryEndorsements(tx *sql.Tx, where string, args ...any) (list []spec.Endorsement, err error) {
+	rows, err := tx.Query("SELECT endorser,subject,level,payload,sig,time FROM endorse "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e spec.Endorsement
+		err = rows.Scan(&e.Endorser, &e.Subject, &e.Level, &e.Payload, &e.Sig, &e.Time)
+		if err != nil {
+			return nil, dbErr(err, "queryEndorsements: scanning row")
+		}
+		list = append(list, e)
+	}
+	if err = rows.Err(); err != nil { // docs say this check is required!
+		return nil, dbErr(err, "queryEndorsements: query")
+	}
+	return list, nil
+}
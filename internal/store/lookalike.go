@@ -0,0 +1,257 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"code.dogecoin.org/gossip/iden"
+	"code.dogecoin.org/identity/internal/profile"
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// Identity names are indexed by their confusables skeleton (see
+// profile.Skeleton) with the time we first saw each identity using that
+// name, so that lookalikes of pinned contacts and of older identities can
+// be found.
+
+// setSkeleton updates the skeleton index for an identity (in a transaction)
+func setSkeleton(tx *sql.Tx, pubkey []byte, msg iden.IdentityMsg) error {
+	return setSkeletonFirst(tx, pubkey, msg, time.Now().Unix())
+}
+
+// setSkeletonFirst is setSkeleton with the first-seen time for a new entry.
+func setSkeletonFirst(tx *sql.Tx, pubkey []byte, msg iden.IdentityMsg, first int64) error {
+	skel := profile.Skeleton(msg.Name)
+	if skel == "" {
+		_, err := tx.Exec("DELETE FROM skeleton WHERE pubkey=?", pubkey)
+		return err
+	}
+	// a renamed identity is first seen with its new name (otherwise an old
+	// identity could take a newer identity's name and look like the original)
+	_, err := tx.Exec("INSERT INTO skeleton (pubkey,skel,first) VALUES (?,?,?) ON CONFLICT(pubkey) DO UPDATE SET skel=excluded.skel,first=excluded.first WHERE skel<>excluded.skel", pubkey, skel, first)
+	return err
+}
+
+// migrateSkeletons indexes identities that are missing from the skeleton
+// index, and re-indexes names whose skeleton has changed without changing
+// their first-seen time. Add a migration that runs it again whenever
+// profile.Skeleton changes.
+func migrateSkeletons(tx *sql.Tx) error {
+	// the signing time is the best guess at first-seen for new entries
+	rows, err := tx.Query("SELECT i.pubkey,i.payload,i.time,s.skel FROM identity i LEFT JOIN skeleton s ON s.pubkey=i.pubkey")
+	if err != nil {
+		return err
+	}
+	var pubs [][]byte
+	var msgs []iden.IdentityMsg
+	var times []int64
+	var stale [][]byte
+	var skels []string
+	for rows.Next() {
+		var pub, payload []byte
+		var signed int64
+		var old sql.NullString
+		err = rows.Scan(&pub, &payload, &signed, &old)
+		if err != nil {
+			rows.Close()
+			return dbErr(err, "init skeletons: scanning row")
+		}
+		msg, ok := profile.DecodeIdentity(payload)
+		if !ok {
+			continue
+		}
+		if !old.Valid {
+			pubs = append(pubs, pub)
+			msgs = append(msgs, msg)
+			times = append(times, signed)
+		} else if skel := profile.Skeleton(msg.Name); skel != old.String {
+			stale = append(stale, pub)
+			skels = append(skels, skel)
+		}
+	}
+	err = rows.Err() // docs say this check is required!
+	rows.Close()
+	if err != nil {
+		return dbErr(err, "init skeletons: query")
+	}
+	for i, pub := range pubs {
+		err = setSkeletonFirst(tx, pub, msgs[i], times[i])
+		if err != nil {
+			return err
+		}
+	}
+	for i, pub := range stale {
+		if skels[i] == "" {
+			_, err = tx.Exec("DELETE FROM skeleton WHERE pubkey=?", pub)
+		} else {
+			_, err = tx.Exec("UPDATE skeleton SET skel=? WHERE pubkey=?", skels[i], pub)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return initContactSkeletons(tx)
+}
+
+// initContactSkeletons re-indexes contact names whose skeleton has changed.
+func initContactSkeletons(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT pubkey,name,skel FROM contact")
+	if err != nil {
+		return err
+	}
+	var pubs [][]byte
+	var skels []string
+	for rows.Next() {
+		var pub []byte
+		var name, old string
+		err = rows.Scan(&pub, &name, &old)
+		if err != nil {
+			rows.Close()
+			return dbErr(err, "init contact skeletons: scanning row")
+		}
+		if skel := profile.Skeleton(name); skel != old {
+			pubs = append(pubs, pub)
+			skels = append(skels, skel)
+		}
+	}
+	err = rows.Err() // docs say this check is required!
+	rows.Close()
+	if err != nil {
+		return dbErr(err, "init contact skeletons: query")
+	}
+	for i, pub := range pubs {
+		_, err = tx.Exec("UPDATE contact SET skel=? WHERE pubkey=?", skels[i], pub)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s SQLiteStoreCtx) PinContact(pubkey []byte, name string) error {
+	return s.doTxn("PinContact", func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO contact (pubkey,name,skel,time) VALUES (?,?,?,?) ON CONFLICT(pubkey) DO UPDATE SET name=excluded.name,skel=excluded.skel", pubkey, name, profile.Skeleton(name), time.Now().Unix())
+		return err
+	})
+}
+
+func (s SQLiteStoreCtx) UnpinContact(pubkey []byte) error {
+	return s.doTxn("UnpinContact", func(tx *sql.Tx) error {
+		res, err := tx.Exec("DELETE FROM contact WHERE pubkey=?", pubkey)
+		if err != nil {
+			return err
+		}
+		num, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if num == 0 {
+			return spec.ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) ListContacts() (list []spec.Contact, err error) {
+	err = s.doTxn("ListContacts", func(tx *sql.Tx) error {
+		list = nil
+		rows, err := tx.Query("SELECT pubkey,name,time FROM contact ORDER BY name")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var c spec.Contact
+			err = rows.Scan(&c.PubKey, &c.Name, &c.Time)
+			if err != nil {
+				return dbErr(err, "ListContacts: scanning row")
+			}
+			list = append(list, c)
+		}
+		if err = rows.Err(); err != nil { // docs say this check is required!
+			return dbErr(err, "ListContacts: query")
+		}
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) FindLookalike(pubkey []byte) (found *spec.Lookalike, err error) {
+	err = s.doTxn("FindLookalike", func(tx *sql.Tx) error {
+		found = nil
+		var skel string
+		var first int64
+		err := tx.QueryRow("SELECT skel,first FROM skeleton WHERE pubkey=?", pubkey).Scan(&skel, &first)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil // not stored, or has no name
+		}
+		if err != nil {
+			return dbErr(err, "FindLookalike: SELECT skeleton")
+		}
+		// pinned contacts are trusted
+		var pinned int
+		err = tx.QueryRow("SELECT COUNT(*) FROM contact WHERE pubkey=?", pubkey).Scan(&pinned)
+		if err != nil {
+			return dbErr(err, "FindLookalike: SELECT contact")
+		}
+		if pinned > 0 {
+			return nil
+		}
+		// name looks like a pinned contact
+		var c spec.Lookalike
+		err = tx.QueryRow("SELECT pubkey,name FROM contact WHERE skel=? AND pubkey<>? LIMIT 1", skel, pubkey).Scan(&c.PubKey, &c.Name)
+		if err == nil {
+			c.Pinned = true
+			found = &c
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return dbErr(err, "FindLookalike: SELECT contact skel")
+		}
+		// name looks like an identity we saw earlier
+		var payload []byte
+		err = tx.QueryRow("SELECT s.pubkey,i.payload FROM skeleton s JOIN identity i ON i.pubkey=s.pubkey WHERE s.skel=? AND s.pubkey<>? AND s.first<? ORDER BY s.first LIMIT 1", skel, pubkey, first).Scan(&c.PubKey, &payload)
+		if err == nil {
+			if msg, ok := profile.DecodeIdentity(payload); ok {
+				c.Name = msg.Name
+			}
+			found = &c
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return dbErr(err, "FindLookalike: SELECT skeleton skel")
+		}
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) FindIdentitiesByName(query string, limit int) (list []spec.Identity, err error) {
+	skel := profile.Skeleton(query)
+	err = s.doTxn("FindIdentitiesByName", func(tx *sql.Tx) error {
+		list = nil
+		if skel == "" {
+			return nil
+		}
+		// first-seen order: impersonators sort after the original
+		rows, err := tx.Query("SELECT i.pubkey,i.payload,i.sig,i.time FROM skeleton s JOIN identity i ON i.pubkey=s.pubkey WHERE instr(s.skel,?)>0 ORDER BY s.first LIMIT ?", skel, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id spec.Identity
+			err = rows.Scan(&id.PubKey, &id.Payload, &id.Sig, &id.Time)
+			if err != nil {
+				return dbErr(err, "FindIdentitiesByName: scanning row")
+			}
+			list = append(list, id)
+		}
+		if err = rows.Err(); err != nil { // docs say this check is required!
+			return dbErr(err, "FindIdentitiesByName: query")
+		}
+		return nil
+	})
+	return
+}
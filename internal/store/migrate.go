@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"code.dogecoin.org/gossip/iden"
+	"code.dogecoin.org/identity/internal/profile"
+)
+
+// Migrations bring databases created by older versions up to date. Each
+// runs once, in order; config.version is the number that have run (new
+// databases start at len(migrations), see initConfig).
+
+var migrations = []func(tx *sql.Tx) error{
+	migrateIdentityID, // 1: explicit identity ids for the location index
+	migrateSkeletons,  // 2: name skeletons for lookalike checks
+}
+
+// initVersion adds the version column to databases from older versions.
+func (s *SQLiteStore) initVersion(ctx context.Context) error {
+	sctx := SQLiteStoreCtx{_db: s.db, ctx: ctx, feed: s.feed}
+	return sctx.doTxn("init version", func(tx *sql.Tx) error {
+		var found int
+		err := tx.QueryRow("SELECT COUNT(*) FROM pragma_table_info('config') WHERE name='version'").Scan(&found)
+		if err != nil || found > 0 {
+			return err
+		}
+		_, err = tx.Exec("ALTER TABLE config ADD COLUMN version INTEGER NOT NULL DEFAULT 0")
+		return err
+	})
+}
+
+// migrate runs the migrations this database hasn't run yet.
+func (s *SQLiteStore) migrate(ctx context.Context) error {
+	sctx := SQLiteStoreCtx{_db: s.db, ctx: ctx, feed: s.feed}
+	return sctx.doTxn("migrate", func(tx *sql.Tx) error {
+		var version int
+		err := tx.QueryRow("SELECT version FROM config LIMIT 1").Scan(&version)
+		if err != nil {
+			return dbErr(err, "migrate: SELECT config")
+		}
+		for ; version < len(migrations); version++ {
+			err = migrations[version](tx)
+			if err != nil {
+				return fmt.Errorf("migration %v: %w", version+1, err)
+			}
+			_, err = tx.Exec("UPDATE config SET version=?", version+1)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// migrateIdentityID gives identities an INTEGER PRIMARY KEY to key the
+// location index, since VACUUM can renumber the implicit rowids of a table
+// with a BLOB primary key, and re-indexes all locations.
+func migrateIdentityID(tx *sql.Tx) error {
+	_, err := tx.Exec(`CREATE TABLE identity_new (
+	id INTEGER PRIMARY KEY,
+	pubkey BLOB NOT NULL UNIQUE,
+	payload BLOB NOT NULL,
+	sig BLOB NOT NULL,
+	time INTEGER NOT NULL,
+	dayc INTEGER NOT NULL
+)`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO identity_new (pubkey,payload,sig,time,dayc) SELECT pubkey,payload,sig,time,dayc FROM identity")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DROP TABLE identity")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("ALTER TABLE identity_new RENAME TO identity")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM location")
+	if err != nil {
+		return err
+	}
+	rows, err := tx.Query("SELECT pubkey,payload FROM identity")
+	if err != nil {
+		return err
+	}
+	var pubs [][]byte
+	var msgs []iden.IdentityMsg
+	for rows.Next() {
+		var pub, payload []byte
+		err = rows.Scan(&pub, &payload)
+		if err != nil {
+			rows.Close()
+			return dbErr(err, "migrate identity ids: scanning row")
+		}
+		if msg, ok := profile.DecodeIdentity(payload); ok {
+			pubs = append(pubs, pub)
+			msgs = append(msgs, msg)
+		}
+	}
+	err = rows.Err() // docs say this check is required!
+	rows.Close()
+	if err != nil {
+		return dbErr(err, "migrate identity ids: query")
+	}
+	for i, pub := range pubs {
+		err = setLocation(tx, pub, msgs[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// Petnames are local to this node: they are not trimmed with expired
+// identities, so they survive an identity re-announcing later.
+
+func (s SQLiteStoreCtx) SetPetname(pubkey []byte, petname string, note string) error {
+	return s.doTxn("SetPetname", func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO petname (pubkey,petname,note,time) VALUES (?,?,?,?) ON CONFLICT(pubkey) DO UPDATE SET petname=excluded.petname,note=excluded.note,time=excluded.time", pubkey, petname, note, time.Now().Unix())
+		return err
+	})
+}
+
+func (s SQLiteStoreCtx) GetPetname(pubkey []byte) (p spec.Petname, err error) {
+	err = s.doTxn("GetPetname", func(tx *sql.Tx) error {
+		p = spec.Petname{PubKey: pubkey}
+		err := tx.QueryRow("SELECT petname,note,time FROM petname WHERE pubkey=?", pubkey).Scan(&p.Petname, &p.Note, &p.Time)
+		if errors.Is(err, sql.ErrNoRows) {
+			return spec.ErrNotFound
+		}
+		if err != nil {
+			return dbErr(err, "GetPetname: SELECT")
+		}
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) RemovePetname(pubkey []byte) error {
+	return s.doTxn("RemovePetname", func(tx *sql.Tx) error {
+		res, err := tx.Exec("DELETE FROM petname WHERE pubkey=?", pubkey)
+		if err != nil {
+			return err
+		}
+		num, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if num == 0 {
+			return spec.ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) ListPetnames() (list []spec.Petname, err error) {
+	err = s.doTxn("ListPetnames", func(tx *sql.Tx) error {
+		list = nil
+		rows, err := tx.Query("SELECT pubkey,petname,note,time FROM petname ORDER BY petname")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var p spec.Petname
+			err = rows.Scan(&p.PubKey, &p.Petname, &p.Note, &p.Time)
+			if err != nil {
+				return dbErr(err, "ListPetnames: scanning row")
+			}
+			list = append(list, p)
+		}
+		if err = rows.Err(); err != nil { // docs say this check is required!
+			return dbErr(err, "ListPetnames: query")
+		}
+		return nil
+	})
+	return
+}
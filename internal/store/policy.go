@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// Policy rules are either local (source "") or imported from a blocklist.
+// Local rules replace imported rules with the same kind and value, and
+// imports never replace local rules, so importing a shared blocklist cannot
+// turn the operator's allow rules into blocks.
+
+// initPolicySource adds the source column to older databases
+// (rules stored before then are treated as local).
+func (s *SQLiteStore) initPolicySource(ctx context.Context) error {
+	sctx := SQLiteStoreCtx{_db: s.db, ctx: ctx, feed: s.feed}
+	return sctx.doTxn("init policy source", func(tx *sql.Tx) error {
+		var found int
+		err := tx.QueryRow("SELECT COUNT(*) FROM pragma_table_info('policy') WHERE name='source'").Scan(&found)
+		if err != nil || found > 0 {
+			return err
+		}
+		_, err = tx.Exec("ALTER TABLE policy ADD COLUMN source TEXT NOT NULL DEFAULT ''")
+		return err
+	})
+}
+
+func (s SQLiteStoreCtx) SetPolicyRules(rules []spec.PolicyRule) error {
+	return s.doTxn("SetPolicyRules", func(tx *sql.Tx) error {
+		now := time.Now().Unix()
+		for _, r := range rules {
+			_, err := tx.Exec("INSERT INTO policy (kind,value,allow,reason,time,source) VALUES (?,?,?,?,?,'') ON CONFLICT(kind,value) DO UPDATE SET allow=excluded.allow,reason=excluded.reason,time=excluded.time,source=''", r.Kind, r.Value, r.Allow, r.Reason, now)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) ImportPolicyRules(source string, rules []spec.PolicyRule) error {
+	return s.doTxn("ImportPolicyRules", func(tx *sql.Tx) error {
+		// replace the rules previously imported from this source
+		_, err := tx.Exec("DELETE FROM policy WHERE source=?", source)
+		if err != nil {
+			return err
+		}
+		now := time.Now().Unix()
+		for _, r := range rules {
+			// keep existing rules (local, or from another source)
+			_, err := tx.Exec("INSERT INTO policy (kind,value,allow,reason,time,source) VALUES (?,?,?,?,?,?) ON CONFLICT(kind,value) DO NOTHING", r.Kind, r.Value, r.Allow, r.Reason, now, source)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) RemovePolicyRule(kind string, value string) error {
+	return s.doTxn("RemovePolicyRule", func(tx *sql.Tx) error {
+		res, err := tx.Exec("DELETE FROM policy WHERE kind=? AND value=?", kind, value)
+		if err != nil {
+			return err
+		}
+		num, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if num == 0 {
+			return spec.ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) ListPolicyRules() (list []spec.PolicyRule, err error) {
+	err = s.doTxn("ListPolicyRules", func(tx *sql.Tx) error {
+		list = nil
+		rows, err := tx.Query("SELECT kind,value,allow,reason,source,time FROM policy ORDER BY kind,value")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var r spec.PolicyRule
+			err = rows.Scan(&r.Kind, &r.Value, &r.Allow, &r.Reason, &r.Source, &r.Time)
+			if err != nil {
+				return dbErr(err, "ListPolicyRules: scanning row")
+			}
+			list = append(list, r)
+		}
+		if err = rows.Err(); err != nil { // docs say this check is required!
+			return dbErr(err, "ListPolicyRules: query")
+		}
+		return nil
+	})
+	return
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+
+	"code.dogecoin.org/gossip/iden"
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// Identity locations are indexed in the `location` R*Tree, keyed by the
+// identity id, in the same tenths of a degree used in IdentityMsg.
+// Identities at 0,0 have no location and are not indexed.
+
+const EarthRadiusKm = 6371.0
+const KmPerDegree = EarthRadiusKm * math.Pi / 180.0
+
+// setLocation updates the location index for an identity (in a transaction)
+func setLocation(tx *sql.Tx, pubkey []byte, msg iden.IdentityMsg) error {
+	_, err := tx.Exec("DELETE FROM location WHERE id=(SELECT id FROM identity WHERE pubkey=?)", pubkey)
+	if err != nil {
+		return err
+	}
+	if msg.Lat == 0 && msg.Long == 0 {
+		return nil // no location
+	}
+	_, err = tx.Exec("INSERT INTO location (id,minLat,maxLat,minLon,maxLon) SELECT id,?,?,?,? FROM identity WHERE pubkey=?", msg.Lat, msg.Lat, msg.Long, msg.Long, pubkey)
+	return err
+}
+
+func (s SQLiteStoreCtx) FindIdentitiesInBox(minLat, minLon, maxLat, maxLon float64, limit int) (list []spec.Identity, err error) {
+	err = s.doTxn("FindIdentitiesInBox", func(tx *sql.Tx) error {
+		list = nil
+		return queryBox(tx, minLat, minLon, maxLat, maxLon, limit, func(id spec.Identity, lat, lon float64) {
+			list = append(list, id)
+		})
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) FindIdentitiesNear(lat, lon float64, km float64, limit int) (list []spec.NearbyIdentity, err error) {
+	// bounding box around the circle
+	dLat := km / KmPerDegree
+	minLat, maxLat := lat-dLat, lat+dLat
+	minLon, maxLon := -180.0, 180.0
+	if minLat > -90 && maxLat < 90 {
+		// otherwise the circle contains a pole: all longitudes
+		dLon := km / (KmPerDegree * math.Cos(lat*math.Pi/180.0))
+		if dLon < 180 {
+			minLon, maxLon = wrapLon(lon-dLon), wrapLon(lon+dLon)
+		}
+	}
+	err = s.doTxn("FindIdentitiesNear", func(tx *sql.Tx) error {
+		list = nil
+		return queryBox(tx, math.Max(minLat, -90), minLon, math.Min(maxLat, 90), maxLon, -1, func(id spec.Identity, idLat, idLon float64) {
+			dist := Haversine(lat, lon, idLat, idLon)
+			if dist <= km {
+				list = append(list, spec.NearbyIdentity{Identity: id, Distance: dist})
+			}
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Distance < list[j].Distance })
+	if limit >= 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+// queryBox calls found for each identity in the box (limit -1 for no limit)
+func queryBox(tx *sql.Tx, minLat, minLon, maxLat, maxLon float64, limit int, found func(id spec.Identity, lat, lon float64)) error {
+	// degrees to the tenths used in the index (rounding outwards)
+	qMinLat, qMaxLat := int(math.Floor(minLat*10)), int(math.Ceil(maxLat*10))
+	qMinLon, qMaxLon := int(math.Floor(minLon*10)), int(math.Ceil(maxLon*10))
+	query := "SELECT i.pubkey,i.payload,i.sig,i.time,l.minLat,l.minLon FROM location l JOIN identity i ON i.id=l.id WHERE l.maxLat>=? AND l.minLat<=? AND "
+	if minLon <= maxLon {
+		query += "l.maxLon>=? AND l.minLon<=?"
+	} else {
+		query += "(l.maxLon>=? OR l.minLon<=?)" // crosses the antimeridian
+	}
+	rows, err := tx.Query(query+" LIMIT ?", qMinLat, qMaxLat, qMinLon, qMaxLon, limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id spec.Identity
+		var lat, lon int
+		err = rows.Scan(&id.PubKey, &id.Payload, &id.Sig, &id.Time, &lat, &lon)
+		if err != nil {
+			return dbErr(err, "queryBox: scanning row")
+		}
+		found(id, float64(lat)/10.0, float64(lon)/10.0) // undo quantization
+	}
+	if err = rows.Err(); err != nil { // docs say this check is required!
+		return dbErr(err, "queryBox: query")
+	}
+	return nil
+}
+
+func wrapLon(lon float64) float64 {
+	if lon < -180 {
+		return lon + 360
+	}
+	if lon > 180 {
+		return lon - 360
+	}
+	return lon
+}
+
+// Haversine returns the great-circle distance in km between two points.
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	const rad = math.Pi / 180.0
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -5,21 +5,36 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	"code.dogecoin.org/identity/internal/metrics"
+	"code.dogecoin.org/identity/internal/profile"
 	"code.dogecoin.org/identity/internal/spec"
 	"github.com/mattn/go-sqlite3"
 )
 
 const SecondsPerDay = 24 * 60 * 60
 
+// BusyTimeout is how long SQLite waits for another connection's lock
+// (e.g. a command-line tool and the service sharing the database)
+const BusyTimeout = 5 * time.Second
+
 type SQLiteStore struct {
-	db *sql.DB
+	db   *sql.DB
+	feed *changeFeed
 }
 
 type SQLiteStoreCtx struct {
-	_db *sql.DB
-	ctx context.Context
+	_db  *sql.DB
+	ctx  context.Context
+	feed *changeFeed
+}
+
+// changeFeed wakes up listeners when a Change is stored.
+type changeFeed struct {
+	mu     sync.Mutex
+	signal chan struct{}
 }
 
 var _ spec.Store = &SQLiteStore{}
@@ -35,7 +50,8 @@ type Queryable interface {
 const SQL_SCHEMA string = `
 CREATE TABLE IF NOT EXISTS config (
 	dayc INTEGER NOT NULL,
-	last INTEGER NOT NULL
+	last INTEGER NOT NULL,
+	version INTEGER NOT NULL DEFAULT 0
 );
 CREATE TABLE IF NOT EXISTS announce (
 	payload BLOB NOT NULL,
@@ -43,7 +59,8 @@ CREATE TABLE IF NOT EXISTS announce (
 	time INTEGER NOT NULL
 );
 CREATE TABLE IF NOT EXISTS identity (
-	pubkey BLOB PRIMARY KEY NOT NULL,
+	id INTEGER PRIMARY KEY,
+	pubkey BLOB NOT NULL UNIQUE,
 	payload BLOB NOT NULL,
 	sig BLOB NOT NULL,
 	time INTEGER NOT NULL,
@@ -62,13 +79,79 @@ CREATE TABLE IF NOT EXISTS nodes (
 	pubkey BLOB PRIMARY KEY NOT NULL,
 	time INTEGER NOT NULL
 );
+CREATE TABLE IF NOT EXISTS claim (
+	node BLOB PRIMARY KEY NOT NULL,
+	identity BLOB NOT NULL,
+	time INTEGER NOT NULL,
+	dayc INTEGER NOT NULL
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS location USING rtree_i32(
+	id,
+	minLat, maxLat,
+	minLon, maxLon
+);
+CREATE TABLE IF NOT EXISTS skeleton (
+	pubkey BLOB PRIMARY KEY NOT NULL,
+	skel TEXT NOT NULL,
+	first INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS skeleton_skel ON skeleton (skel);
+CREATE TABLE IF NOT EXISTS contact (
+	pubkey BLOB PRIMARY KEY NOT NULL,
+	name TEXT NOT NULL,
+	skel TEXT NOT NULL,
+	time INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS contact_skel ON contact (skel);
+CREATE TABLE IF NOT EXISTS petname (
+	pubkey BLOB PRIMARY KEY NOT NULL,
+	petname TEXT NOT NULL,
+	note TEXT NOT NULL,
+	time INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS policy (
+	kind TEXT NOT NULL,
+	value TEXT NOT NULL,
+	allow INTEGER NOT NULL,
+	reason TEXT NOT NULL,
+	time INTEGER NOT NULL,
+	source TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (kind, value)
+);
+CREATE TABLE IF NOT EXISTS domain (
+	address TEXT PRIMARY KEY NOT NULL,
+	pubkey BLOB NOT NULL,
+	verified INTEGER NOT NULL,
+	checked INTEGER NOT NULL,
+	error TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS domain_pubkey ON domain (pubkey);
+CREATE TABLE IF NOT EXISTS endorse (
+	endorser BLOB NOT NULL,
+	subject BLOB NOT NULL,
+	level INTEGER NOT NULL,
+	payload BLOB NOT NULL,
+	sig BLOB NOT NULL,
+	time INTEGER NOT NULL,
+	dayc INTEGER NOT NULL,
+	PRIMARY KEY (endorser, subject)
+);
+CREATE INDEX IF NOT EXISTS endorse_subject ON endorse (subject);
+CREATE TABLE IF NOT EXISTS change (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL,
+	pubkey BLOB NOT NULL,
+	time INTEGER NOT NULL,
+	dayc INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS change_pubkey ON change (pubkey);
 `
 
 // New returns a spec.Store implementation that uses SQLite
 func New(fileName string, ctx context.Context) (spec.Store, error) {
 	backend := "sqlite3"
-	db, err := sql.Open(backend, fileName)
-	store := &SQLiteStore{db: db}
+	db, err := sql.Open(backend, fmt.Sprintf("%s?_busy_timeout=%d", fileName, BusyTimeout.Milliseconds()))
+	store := &SQLiteStore{db: db, feed: &changeFeed{signal: make(chan struct{})}}
 	if err != nil {
 		return store, dbErr(err, "opening database")
 	}
@@ -83,8 +166,23 @@ func New(fileName string, ctx context.Context) (spec.Store, error) {
 	if err != nil {
 		return store, dbErr(err, "creating database schema")
 	}
+	// add the version column to databases from older versions
+	err = store.initVersion(ctx)
+	if err != nil {
+		return store, err
+	}
 	// init config table
 	err = store.initConfig(ctx)
+	if err != nil {
+		return store, err
+	}
+	// migrate databases from older versions
+	err = store.migrate(ctx)
+	if err != nil {
+		return store, err
+	}
+	// add the policy source column to databases from older versions
+	err = store.initPolicySource(ctx)
 	return store, err
 }
 
@@ -93,7 +191,7 @@ func (s *SQLiteStore) Close() {
 }
 
 func (s *SQLiteStore) initConfig(ctx context.Context) error {
-	sctx := SQLiteStoreCtx{_db: s.db, ctx: ctx}
+	sctx := SQLiteStoreCtx{_db: s.db, ctx: ctx, feed: s.feed}
 	return sctx.doTxn("init config", func(tx *sql.Tx) error {
 		config := tx.QueryRow("SELECT dayc,last FROM config LIMIT 1")
 		var dayc int64
@@ -101,7 +199,8 @@ func (s *SQLiteStore) initConfig(ctx context.Context) error {
 		err := config.Scan(&dayc, &last)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				_, err = tx.Exec("INSERT INTO config (dayc,last) VALUES (1,?)", unixDayStamp())
+				// a new database needs no migrations
+				_, err = tx.Exec("INSERT INTO config (dayc,last,version) VALUES (1,?,?)", unixDayStamp(), len(migrations))
 			}
 			return err
 		}
@@ -111,11 +210,26 @@ func (s *SQLiteStore) initConfig(ctx context.Context) error {
 
 func (s *SQLiteStore) WithCtx(ctx context.Context) spec.StoreCtx {
 	return &SQLiteStoreCtx{
-		_db: s.db,
-		ctx: ctx,
+		_db:  s.db,
+		ctx:  ctx,
+		feed: s.feed,
 	}
 }
 
+func (s *SQLiteStore) Changed() <-chan struct{} {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+	return s.feed.signal
+}
+
+// notify wakes up all listeners waiting in Changed()
+func (f *changeFeed) notify() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	close(f.signal)
+	f.signal = make(chan struct{})
+}
+
 // The number of whole days since the unix epoch.
 func unixDayStamp() int64 {
 	return time.Now().Unix() / SecondsPerDay
@@ -146,6 +260,7 @@ func (s SQLiteStoreCtx) doTxn(name string, work func(tx *sql.Tx) error) error {
 		tx, err := db.Begin()
 		if err != nil {
 			if IsConflict(err) {
+				metrics.TxnRetries.With(name).Inc()
 				s.Sleep(250 * time.Millisecond)
 				limit--
 				if limit != 0 {
@@ -158,6 +273,7 @@ func (s SQLiteStoreCtx) doTxn(name string, work func(tx *sql.Tx) error) error {
 		err = work(tx)
 		if err != nil {
 			if IsConflict(err) {
+				metrics.TxnRetries.With(name).Inc()
 				s.Sleep(250 * time.Millisecond)
 				limit--
 				if limit != 0 {
@@ -169,6 +285,7 @@ func (s SQLiteStoreCtx) doTxn(name string, work func(tx *sql.Tx) error) error {
 		err = tx.Commit()
 		if err != nil {
 			if IsConflict(err) {
+				metrics.TxnRetries.With(name).Inc()
 				s.Sleep(250 * time.Millisecond)
 				limit--
 				if limit != 0 {
@@ -211,7 +328,10 @@ func dbErr(err error, where string) error {
 // STORE INTERFACE
 
 func (s SQLiteStoreCtx) SetIdentity(pubkey []byte, payload []byte, sig []byte, time int64) error {
-	return s.doTxn("SetIdentity", func(tx *sql.Tx) error {
+	var kind string
+	msg, valid := profile.DecodeIdentity(payload)
+	err := s.doTxn("SetIdentity", func(tx *sql.Tx) error {
+		kind = ""
 		// identity expires after 30 days
 		res, err := tx.Exec("UPDATE identity SET payload=?,sig=?,time=?,dayc=30+(SELECT dayc FROM config LIMIT 1) WHERE pubkey=? AND time<?", payload, sig, time, pubkey, time)
 		if err != nil {
@@ -226,9 +346,35 @@ func (s SQLiteStoreCtx) SetIdentity(pubkey []byte, payload []byte, sig []byte, t
 			if IsConstraint(err) {
 				return nil // key conflict: means the new time was earlier than the stored record.
 			}
+			if err != nil {
+				return err
+			}
+			kind = spec.ChangeNew
+		} else if valid && len(msg.Nodes) > 0 {
+			kind = spec.ChangeUpdated
+		} else {
+			kind = spec.ChangeRevoked // re-signed without any nodes
 		}
-		return err
+		err = setLocation(tx, pubkey, msg)
+		if err != nil {
+			return err
+		}
+		err = setSkeleton(tx, pubkey, msg)
+		if err != nil {
+			return err
+		}
+		return addChange(tx, kind, pubkey)
 	})
+	if err == nil && kind != "" {
+		s.feed.notify()
+	}
+	return err
+}
+
+// addChange appends to the change feed; changes are kept for 7 days.
+func addChange(tx *sql.Tx, kind string, pubkey []byte) error {
+	_, err := tx.Exec("INSERT INTO change (kind,pubkey,time,dayc) VALUES (?,?,?,7+(SELECT dayc FROM config LIMIT 1))", kind, pubkey, time.Now().Unix())
+	return err
 }
 
 func (s SQLiteStoreCtx) GetIdentity(pubkey []byte) (payload []byte, sig []byte, time int64, err error) {
@@ -247,6 +393,87 @@ func (s SQLiteStoreCtx) GetIdentity(pubkey []byte) (payload []byte, sig []byte,
 	return
 }
 
+func (s SQLiteStoreCtx) GetIdentityExpiry(pubkey []byte) (expires int64, err error) {
+	err = s.doTxn("GetIdentityExpiry", func(tx *sql.Tx) error {
+		// Trim advances the day counter once per day, and deletes records
+		// whose dayc is then less than the counter (see Trim)
+		var day int64
+		row := tx.QueryRow("SELECT c.last+1+i.dayc-c.dayc FROM identity i, config c WHERE i.pubkey=? LIMIT 1", pubkey)
+		e := row.Scan(&day)
+		if e != nil {
+			if errors.Is(e, sql.ErrNoRows) {
+				return spec.ErrNotFound
+			} else {
+				return fmt.Errorf("GetIdentityExpiry: %w", e)
+			}
+		}
+		expires = day * SecondsPerDay
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) ListIdentities(after []byte, limit int) (list []spec.Identity, err error) {
+	err = s.doTxn("ListIdentities", func(tx *sql.Tx) error {
+		list = nil
+		if after == nil {
+			after = []byte{} // sorts before all blobs
+		}
+		rows, err := tx.Query("SELECT pubkey,payload,sig,time FROM identity WHERE pubkey>? ORDER BY pubkey LIMIT ?", after, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id spec.Identity
+			err = rows.Scan(&id.PubKey, &id.Payload, &id.Sig, &id.Time)
+			if err != nil {
+				return dbErr(err, "ListIdentities: scanning row")
+			}
+			list = append(list, id)
+		}
+		if err = rows.Err(); err != nil { // docs say this check is required!
+			return dbErr(err, "ListIdentities: query")
+		}
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) Ping() error {
+	return s.doTxn("Ping", func(tx *sql.Tx) error {
+		var one int
+		err := tx.QueryRow("SELECT 1 FROM config LIMIT 1").Scan(&one)
+		if err != nil {
+			return dbErr(err, "Ping: SELECT config")
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) CheckWritable() error {
+	return s.doTxn("CheckWritable", func(tx *sql.Tx) error {
+		// a no-op write still needs the write lock and a writable file
+		_, err := tx.Exec("UPDATE config SET dayc=dayc")
+		if err != nil {
+			return dbErr(err, "CheckWritable: UPDATE config")
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) CountIdentities() (count int64, err error) {
+	err = s.doTxn("CountIdentities", func(tx *sql.Tx) error {
+		row := tx.QueryRow("SELECT COUNT(*) FROM identity")
+		err := row.Scan(&count)
+		if err != nil {
+			return dbErr(err, "CountIdentities: scanning row")
+		}
+		return nil
+	})
+	return
+}
+
 func (s SQLiteStoreCtx) ChooseIdentity() (pubkey []byte, payload []byte, sig []byte, time int64, err error) {
 	err = s.doTxn("ChooseIdentity", func(tx *sql.Tx) error {
 		row := tx.QueryRow("SELECT pubkey,payload,sig,time FROM identity WHERE oid IN (SELECT oid FROM identity ORDER BY RANDOM() LIMIT 1)")
@@ -370,6 +597,114 @@ func (s SQLiteStoreCtx) AddProfileNode(pubkey []byte) error {
 	})
 }
 
+func (s SQLiteStoreCtx) ListProfileNodes() (list []spec.ProfileNode, err error) {
+	err = s.doTxn("ListProfileNodes", func(tx *sql.Tx) error {
+		list = nil
+		rows, err := tx.Query("SELECT pubkey,time FROM nodes ORDER BY time DESC")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var node spec.ProfileNode
+			err = rows.Scan(&node.PubKey, &node.Time)
+			if err != nil {
+				return dbErr(err, "ListProfileNodes: scanning row")
+			}
+			list = append(list, node)
+		}
+		if err = rows.Err(); err != nil { // docs say this check is required!
+			return dbErr(err, "ListProfileNodes: query")
+		}
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) RemoveProfileNode(pubkey []byte) error {
+	return s.doTxn("RemoveProfileNode", func(tx *sql.Tx) error {
+		res, err := tx.Exec("DELETE FROM nodes WHERE pubkey=?", pubkey)
+		if err != nil {
+			return err
+		}
+		num, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if num == 0 {
+			return spec.ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) ExpireProfileNodes(before int64, keep []byte) (expired [][]byte, err error) {
+	err = s.doTxn("ExpireProfileNodes", func(tx *sql.Tx) error {
+		expired = nil
+		if keep == nil {
+			keep = []byte{} // never matches
+		}
+		rows, err := tx.Query("SELECT pubkey FROM nodes WHERE time<? AND pubkey<>?", before, keep)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var pub []byte
+			err = rows.Scan(&pub)
+			if err != nil {
+				rows.Close()
+				return dbErr(err, "ExpireProfileNodes: scanning row")
+			}
+			expired = append(expired, pub)
+		}
+		err = rows.Err() // docs say this check is required!
+		rows.Close()
+		if err != nil {
+			return dbErr(err, "ExpireProfileNodes: query")
+		}
+		_, err = tx.Exec("DELETE FROM nodes WHERE time<? AND pubkey<>?", before, keep)
+		return err
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) SetNodeClaim(node []byte, identity []byte, time int64) error {
+	return s.doTxn("SetNodeClaim", func(tx *sql.Tx) error {
+		// node claim expires after 30 days
+		res, err := tx.Exec("UPDATE claim SET identity=?,time=?,dayc=30+(SELECT dayc FROM config LIMIT 1) WHERE node=? AND time<?", identity, time, node, time)
+		if err != nil {
+			return err
+		}
+		num, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if num == 0 {
+			_, err = tx.Exec("INSERT INTO claim (node,identity,time,dayc) VALUES (?,?,?,30+(SELECT dayc FROM config LIMIT 1))", node, identity, time)
+			if IsConstraint(err) {
+				return nil // key conflict: means the new time was earlier than the stored record.
+			}
+		}
+		return err
+	})
+}
+
+func (s SQLiteStoreCtx) GetNodeClaim(node []byte) (identity []byte, time int64, err error) {
+	err = s.doTxn("GetNodeClaim", func(tx *sql.Tx) error {
+		row := tx.QueryRow("SELECT identity,time FROM claim WHERE node=? LIMIT 1", node)
+		e := row.Scan(&identity, &time)
+		if e != nil {
+			if errors.Is(e, sql.ErrNoRows) {
+				return spec.ErrNotFound
+			} else {
+				return fmt.Errorf("GetNodeClaim: %w", e)
+			}
+		}
+		return nil
+	})
+	return
+}
+
 // Trim expires records after N days.
 //
 // To take account of the possibility that this software has not
@@ -382,6 +717,7 @@ func (s SQLiteStoreCtx) AddProfileNode(pubkey []byte) error {
 //
 // This causes expiry to lag by the number of offline days.
 func (s SQLiteStoreCtx) Trim() (advanced bool, err error) {
+	var expired int64
 	err = s.doTxn("Trim", func(tx *sql.Tx) error {
 		// check if date has changed
 		row := tx.QueryRow("SELECT dayc,last FROM config LIMIT 1")
@@ -400,11 +736,101 @@ func (s SQLiteStoreCtx) Trim() (advanced bool, err error) {
 			if err != nil {
 				return fmt.Errorf("Trim: UPDATE: %v", err)
 			}
+			// record expired identities in the change feed
+			res, err := tx.Exec("INSERT INTO change (kind,pubkey,time,dayc) SELECT ?,pubkey,?,7+? FROM identity WHERE dayc < ?", spec.ChangeExpired, time.Now().Unix(), dayc, dayc)
+			if err != nil {
+				return fmt.Errorf("Trim: INSERT change: %v", err)
+			}
+			expired, err = res.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("Trim: INSERT change: %v", err)
+			}
 			// expire identities
+			_, err = tx.Exec("DELETE FROM location WHERE id IN (SELECT id FROM identity WHERE dayc < ?)", dayc)
+			if err != nil {
+				return fmt.Errorf("Trim: DELETE location: %v", err)
+			}
+			_, err = tx.Exec("DELETE FROM skeleton WHERE pubkey IN (SELECT pubkey FROM identity WHERE dayc < ?)", dayc)
+			if err != nil {
+				return fmt.Errorf("Trim: DELETE skeleton: %v", err)
+			}
 			_, err = tx.Exec("DELETE FROM identity WHERE dayc < ?", dayc)
 			if err != nil {
 				return fmt.Errorf("Trim: DELETE: %v", err)
 			}
+			// expire endorsements
+			_, err = tx.Exec("DELETE FROM endorse WHERE dayc < ?", dayc)
+			if err != nil {
+				return fmt.Errorf("Trim: DELETE endorse: %v", err)
+			}
+			// expire node claims
+			_, err = tx.Exec("DELETE FROM claim WHERE dayc < ?", dayc)
+			if err != nil {
+				return fmt.Errorf("Trim: DELETE claim: %v", err)
+			}
+			// expire the change feed
+			_, err = tx.Exec("DELETE FROM change WHERE dayc < ?", dayc)
+			if err != nil {
+				return fmt.Errorf("Trim: DELETE change: %v", err)
+			}
+		}
+		return nil
+	})
+	if err == nil && advanced {
+		metrics.Trims.Inc()
+		metrics.IdentitiesExpired.Add(uint64(expired))
+	}
+	if err == nil && expired > 0 {
+		s.feed.notify()
+	}
+	return
+}
+
+func (s SQLiteStoreCtx) GetChanges(after int64, limit int) (changes []spec.Change, err error) {
+	err = s.doTxn("GetChanges", func(tx *sql.Tx) error {
+		changes = nil
+		rows, err := tx.Query("SELECT id,kind,pubkey,time FROM change WHERE id>? ORDER BY id LIMIT ?", after, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var c spec.Change
+			err = rows.Scan(&c.ID, &c.Kind, &c.PubKey, &c.Time)
+			if err != nil {
+				return dbErr(err, "GetChanges: scanning row")
+			}
+			changes = append(changes, c)
+		}
+		if err = rows.Err(); err != nil { // docs say this check is required!
+			return dbErr(err, "GetChanges: query")
+		}
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) LastChangeTo(pubkey []byte) (c spec.Change, err error) {
+	err = s.doTxn("LastChangeTo", func(tx *sql.Tx) error {
+		row := tx.QueryRow("SELECT id,kind,pubkey,time FROM change WHERE pubkey=? ORDER BY id DESC LIMIT 1", pubkey)
+		e := row.Scan(&c.ID, &c.Kind, &c.PubKey, &c.Time)
+		if errors.Is(e, sql.ErrNoRows) {
+			return spec.ErrNotFound
+		}
+		if e != nil {
+			return fmt.Errorf("LastChangeTo: %w", e)
+		}
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) LatestChange() (id int64, err error) {
+	err = s.doTxn("LatestChange", func(tx *sql.Tx) error {
+		row := tx.QueryRow("SELECT COALESCE(MAX(id),0) FROM change")
+		e := row.Scan(&id)
+		if e != nil {
+			return fmt.Errorf("LatestChange: %w", e)
 		}
 		return nil
 	})
@@ -0,0 +1,284 @@
+package vcard
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// vCard 4.0 (RFC 6350) encoding of identity profiles, and a tolerant
+// decoder that also reads the vCard 3.0 files many address books export.
+
+const ContentType = "text/vcard; charset=utf-8"
+
+const MaxLineBytes = 75 // fold lines longer than this (in octets)
+
+// Card holds the vCard properties we map to and from a profile.
+type Card struct {
+	UID      string    // e.g. did:doge:<hex>
+	FN       string    // formatted name
+	Nickname string    // e.g. a pinned contact's name
+	Note     string    // bio
+	Locality string    // ADR locality (city)
+	Country  string    // ADR country (ISO 3166-1 alpha-2 on export)
+	HasGeo   bool      // Lat and Lon are set
+	Lat      float64   // WGS84 degrees
+	Lon      float64   // WGS84 degrees
+	Photo    []byte    // image bytes (PNG on export)
+	PhotoExt string    // image media subtype, e.g. "png" or "jpeg"
+	Rev      time.Time // last revision (zero if unknown)
+}
+
+// Encode renders cards as vCard 4.0 (CRLF line endings, folded lines).
+func Encode(cards ...Card) []byte {
+	var buf bytes.Buffer
+	for _, c := range cards {
+		writeLine(&buf, "BEGIN:VCARD")
+		writeLine(&buf, "VERSION:4.0")
+		writeLine(&buf, "KIND:individual")
+		writeLine(&buf, "FN:"+escape(c.FN))
+		if c.Nickname != "" {
+			writeLine(&buf, "NICKNAME:"+escape(c.Nickname))
+		}
+		if c.UID != "" {
+			writeLine(&buf, "UID:"+c.UID)
+		}
+		if c.Note != "" {
+			writeLine(&buf, "NOTE:"+escape(c.Note))
+		}
+		if c.Locality != "" || c.Country != "" {
+			// ;;street;locality;region;code;country
+			writeLine(&buf, "ADR:;;;"+escape(c.Locality)+";;;"+escape(c.Country))
+		}
+		if c.HasGeo {
+			writeLine(&buf, "GEO:geo:"+formatDegrees(c.Lat)+","+formatDegrees(c.Lon))
+		}
+		if len(c.Photo) > 0 {
+			writeLine(&buf, "PHOTO:data:image/"+c.PhotoExt+";base64,"+base64.StdEncoding.EncodeToString(c.Photo))
+		}
+		if !c.Rev.IsZero() {
+			writeLine(&buf, "REV:"+c.Rev.UTC().Format("20060102T150405Z"))
+		}
+		writeLine(&buf, "END:VCARD")
+	}
+	return buf.Bytes()
+}
+
+// writeLine folds a content line at MaxLineBytes without splitting a UTF-8 sequence.
+func writeLine(buf *bytes.Buffer, line string) {
+	limit := MaxLineBytes
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		limit = MaxLineBytes - 1 // continuation lines start with a space
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+func escape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+}
+
+func unescape(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) {
+			i++
+			switch text[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(text[i])
+			}
+			continue
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+func formatDegrees(deg float64) string {
+	return strconv.FormatFloat(deg, 'f', -1, 64)
+}
+
+// Decode reads the cards in a vCard 3.0 or 4.0 file.
+func Decode(data []byte) (cards []Card, err error) {
+	var card *Card
+	for n, line := range unfold(data) {
+		name, params, value, ok := splitLine(line)
+		if !ok {
+			continue // not a content line
+		}
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VCARD"):
+			card = &Card{}
+			continue
+		case name == "END" && strings.EqualFold(value, "VCARD"):
+			if card == nil {
+				return nil, fmt.Errorf("invalid vCard: END without BEGIN (line %v)", n+1)
+			}
+			cards = append(cards, *card)
+			card = nil
+			continue
+		case card == nil:
+			continue
+		}
+		switch name {
+		case "FN":
+			card.FN = unescape(value)
+		case "N":
+			if card.FN == "" {
+				// family;given;additional;prefix;suffix
+				parts := splitValue(value)
+				if len(parts) > 1 {
+					card.FN = strings.TrimSpace(parts[1] + " " + parts[0])
+				} else {
+					card.FN = parts[0]
+				}
+			}
+		case "NICKNAME":
+			card.Nickname = unescape(value)
+		case "UID":
+			card.UID = value
+		case "NOTE":
+			card.Note = unescape(value)
+		case "ADR":
+			if card.Locality == "" && card.Country == "" {
+				parts := splitValue(value)
+				if len(parts) > 3 {
+					card.Locality = parts[3]
+				}
+				if len(parts) > 6 {
+					card.Country = parts[6]
+				}
+			}
+		case "GEO":
+			card.Lat, card.Lon, card.HasGeo = parseGeo(value)
+		case "PHOTO":
+			card.Photo, card.PhotoExt = parsePhoto(params, value)
+		case "REV":
+			for _, layout := range []string{"20060102T150405Z", time.RFC3339, "2006-01-02"} {
+				if t, err := time.Parse(layout, value); err == nil {
+					card.Rev = t
+					break
+				}
+			}
+		}
+	}
+	if card != nil {
+		return nil, errors.New("invalid vCard: missing END:VCARD")
+	}
+	if len(cards) == 0 {
+		return nil, errors.New("invalid vCard: no BEGIN:VCARD found")
+	}
+	return cards, nil
+}
+
+// unfold joins continuation lines (starting with a space or tab).
+func unfold(data []byte) (lines []string) {
+	scan := bufio.NewScanner(bytes.NewReader(data))
+	scan.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scan.Scan() {
+		line := strings.TrimRight(scan.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitLine splits "group.NAME;PARAM=x:value" into upper-case name, params and value.
+func splitLine(line string) (name string, params map[string]string, value string, ok bool) {
+	head, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", nil, "", false
+	}
+	parts := strings.Split(head, ";")
+	name = strings.ToUpper(parts[0])
+	if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+		name = name[dot+1:] // strip the group
+	}
+	params = make(map[string]string)
+	for _, p := range parts[1:] {
+		key, val, found := strings.Cut(p, "=")
+		if !found {
+			params["TYPE"] = p // vCard 2.1 style bare type
+			continue
+		}
+		params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+	}
+	return name, params, value, true
+}
+
+// splitValue splits a structured value at unescaped ';'
+func splitValue(value string) (parts []string) {
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ';':
+			parts = append(parts, unescape(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, unescape(value[start:]))
+}
+
+// parseGeo reads "geo:lat,lon" (4.0) or "lat;lon" (3.0)
+func parseGeo(value string) (lat float64, lon float64, ok bool) {
+	value = strings.TrimPrefix(strings.ToLower(value), "geo:")
+	if i := strings.IndexByte(value, ';'); i >= 0 && strings.Contains(value[i:], "=") {
+		value = value[:i] // geo URI parameters, e.g. ;u=10
+	}
+	latStr, lonStr, found := strings.Cut(value, ",")
+	if !found {
+		latStr, lonStr, found = strings.Cut(value, ";")
+	}
+	if !found {
+		return 0, 0, false
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	lon, err2 := strconv.ParseFloat(strings.TrimSpace(lonStr), 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// parsePhoto reads an inline photo: a data: URI (4.0) or ENCODING=b (3.0).
+// Linked photos (URLs) are ignored: we never fetch them.
+func parsePhoto(params map[string]string, value string) ([]byte, string) {
+	ext := ""
+	if strings.HasPrefix(value, "data:") {
+		meta, data, found := strings.Cut(value[5:], ",")
+		if !found || !strings.HasSuffix(meta, ";base64") {
+			return nil, ""
+		}
+		ext = strings.TrimPrefix(strings.TrimSuffix(meta, ";base64"), "image/")
+		value = data
+	} else if enc := strings.ToUpper(params["ENCODING"]); enc == "B" || enc == "BASE64" {
+		ext = params["TYPE"]
+	} else {
+		return nil, ""
+	}
+	photo, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, ""
+	}
+	return photo, strings.ToLower(ext)
+}
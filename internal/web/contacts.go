@@ -0,0 +1,147 @@
+package web
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"code.dogecoin.org/identity/internal/profile"
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// Contact is a pinned identity; names that look like it are flagged.
+type Contact struct {
+	Identity string `json:"identity"` // identity pubkey (hex)
+	Name     string `json:"name"`     // name we know them by
+	Pinned   int64  `json:"pinned"`   // unix time pinned
+}
+
+// PinContact is the request body for POST /contacts
+type PinContact struct {
+	Identity string `json:"identity"` // identity pubkey (hex)
+	Name     string `json:"name"`     // optional: defaults to the identity's current name
+}
+
+// contacts lists or pins contacts:
+// GET /contacts, POST /contacts {"identity":hex,"name":"..."}
+func (a *WebAPI) contacts(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, POST, OPTIONS"
+	switch r.Method {
+	case http.MethodGet:
+		a.sendContacts(w, opts)
+	case http.MethodPost:
+		if !a.authorized(w, r) {
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("bad request: %v", err), http.StatusBadRequest)
+			return
+		}
+		var req PinContact
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error decoding JSON: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		idenPub, err := parseIdentityKey(req.Identity)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name := req.Name
+		if name == "" {
+			// use the name the identity currently announces
+			payload, _, _, err := a.store.GetIdentity(idenPub)
+			if err != nil {
+				if spec.IsNotFoundError(err) {
+					http.Error(w, "identity not found: specify a name", http.StatusBadRequest)
+				} else {
+					http.Error(w, fmt.Sprintf("cannot load identity: %v", err), http.StatusInternalServerError)
+				}
+				return
+			}
+			pro, ok := profile.DecodeIdentity(payload)
+			if !ok || pro.Name == "" {
+				http.Error(w, "identity has no name: specify a name", http.StatusBadRequest)
+				return
+			}
+			name = pro.Name
+		}
+		name, err = profile.NormalizeText("name", name, profile.MaxNameBytes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = a.store.PinContact(idenPub, name)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("cannot pin contact: %v", err), http.StatusInternalServerError)
+			return
+		}
+		a.sendContacts(w, opts)
+	default:
+		options(w, r, opts)
+	}
+}
+
+// contact unpins a contact: DELETE /contacts/{hex}
+func (a *WebAPI) contact(w http.ResponseWriter, r *http.Request) {
+	opts := "DELETE, OPTIONS"
+	if r.Method != http.MethodDelete {
+		options(w, r, opts)
+		return
+	}
+	if !a.authorized(w, r) {
+		return
+	}
+	idenPub, err := parseIdentityKey(strings.TrimPrefix(r.URL.Path, "/contacts/"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = a.store.UnpinContact(idenPub)
+	if err != nil {
+		if spec.IsNotFoundError(err) {
+			http.Error(w, "contact not found", http.StatusNotFound)
+		} else {
+			http.Error(w, fmt.Sprintf("cannot unpin contact: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+	a.sendContacts(w, opts)
+}
+
+func (a *WebAPI) sendContacts(w http.ResponseWriter, opts string) {
+	list, err := a.store.ListContacts()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot list contacts: %v", err), http.StatusInternalServerError)
+		return
+	}
+	res := make([]Contact, 0, len(list))
+	for _, c := range list {
+		res = append(res, Contact{Identity: hex.EncodeToString(c.PubKey), Name: c.Name, Pinned: c.Time})
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
+
+func parseIdentityKey(arg string) ([]byte, error) {
+	pub, err := hex.DecodeString(arg)
+	if err != nil || len(pub) != 32 {
+		return nil, fmt.Errorf("invalid identity pubkey: expecting 32 bytes hex-encoded (got %v)", arg)
+	}
+	return pub, nil
+}
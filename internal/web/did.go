@@ -0,0 +1,124 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.dogecoin.org/identity/internal/did"
+	"code.dogecoin.org/identity/internal/profile"
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// DID resolution (see internal/did), following the Universal Resolver
+// driver interface: GET /1.0/identifiers/did:doge:<hex>
+
+const DIDResolutionContext = "https://w3id.org/did-resolution/v1"
+const DIDContentType = "application/did+ld+json"
+
+// DIDResolution is the DID resolution result.
+type DIDResolution struct {
+	Context            string            `json:"@context"`
+	DIDDocument        *did.Document     `json:"didDocument"`
+	ResolutionMetadata map[string]string `json:"didResolutionMetadata"`
+	DocumentMetadata   any               `json:"didDocumentMetadata"` // *did.Metadata or empty
+}
+
+// resolveDID returns the DID document of a stored identity. Clients that
+// Accept application/did+ld+json get the bare document.
+func (a *WebAPI) resolveDID(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, OPTIONS"
+	if r.Method != http.MethodGet {
+		options(w, r, opts)
+		return
+	}
+	res := DIDResolution{
+		Context:            DIDResolutionContext,
+		ResolutionMetadata: map[string]string{},
+		DocumentMetadata:   map[string]any{},
+	}
+	deactivated := false
+	status := http.StatusOK
+	idenPub, err := did.Parse(strings.TrimPrefix(r.URL.Path, "/1.0/identifiers/"))
+	if err != nil {
+		res.ResolutionMetadata["error"] = "invalidDid"
+		res.ResolutionMetadata["errorMessage"] = err.Error()
+		a.sendDID(w, http.StatusBadRequest, res, opts)
+		return
+	}
+	payload, _, signed, err := a.store.GetIdentity(idenPub)
+	if err == nil {
+		pro, ok := profile.DecodeIdentity(payload)
+		if !ok || a.blocked(idenPub, &pro) {
+			err = spec.ErrNotFound
+		} else {
+			verified := make([]bool, len(pro.Nodes))
+			for i, nodePub := range pro.Nodes {
+				verified[i] = a.nodeClaimsIdentity(nodePub, idenPub)
+			}
+			doc := did.NewDocument(idenPub, &pro, verified)
+			meta := did.NewMetadata(&pro, signed, spec.ExpiryTime, time.Now())
+			res.DIDDocument, res.DocumentMetadata = &doc, &meta
+			deactivated = meta.Deactivated
+		}
+	}
+	if spec.IsNotFoundError(err) {
+		// recently expired identities are still in the change feed
+		change, cerr := a.store.LastChangeTo(idenPub)
+		if cerr == nil && change.Kind == spec.ChangeExpired {
+			doc := did.Deactivated(idenPub)
+			res.DIDDocument = &doc
+			res.DocumentMetadata = &did.Metadata{Deactivated: true}
+			deactivated = true
+			err = nil
+		} else if cerr != nil && !spec.IsNotFoundError(cerr) {
+			log.Printf("[web] cannot load change feed: %v", cerr)
+		}
+	}
+	if err != nil {
+		if spec.IsNotFoundError(err) {
+			res.ResolutionMetadata["error"] = "notFound"
+			status = http.StatusNotFound
+		} else {
+			res.ResolutionMetadata["error"] = "internalError"
+			res.ResolutionMetadata["errorMessage"] = fmt.Sprintf("cannot load identity: %v", err)
+			status = http.StatusInternalServerError
+		}
+		a.sendDID(w, status, res, opts)
+		return
+	}
+	res.ResolutionMetadata["contentType"] = DIDContentType
+	if accept := r.Header.Get("Accept"); strings.Contains(accept, DIDContentType) || strings.Contains(accept, "application/did+json") {
+		bytes, err := json.Marshal(res.DIDDocument)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", DIDContentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+		w.Header().Set("Allow", opts)
+		if deactivated {
+			w.WriteHeader(http.StatusGone)
+		}
+		w.Write(bytes)
+		return
+	}
+	a.sendDID(w, status, res, opts)
+}
+
+func (a *WebAPI) sendDID(w http.ResponseWriter, status int, res DIDResolution, opts string) {
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", `application/ld+json;profile="https://w3id.org/did-resolution"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Allow", opts)
+	w.WriteHeader(status)
+	w.Write(bytes)
+}
@@ -0,0 +1,199 @@
+package web
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.dogecoin.org/identity/internal/domains"
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// DomainClaim links an identity to a name@domain address.
+type DomainClaim struct {
+	Address  string `json:"address"`         // name@domain
+	Identity string `json:"identity"`        // identity pubkey (hex)
+	Verified bool   `json:"verified"`        // the domain's dogeid.json lists the identity
+	Checked  int64  `json:"checked"`         // unix time last checked
+	Error    string `json:"error,omitempty"` // why verification failed
+}
+
+// AddDomainClaim is the request body for POST /domains
+type AddDomainClaim struct {
+	Identity string `json:"identity"` // identity pubkey (hex)
+	Address  string `json:"address"`  // name@domain
+}
+
+// domainClaims lists or verifies domain claims:
+// GET /domains, POST /domains {"identity":hex,"address":"alice@example.org"}
+func (a *WebAPI) domainClaims(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, POST, OPTIONS"
+	switch r.Method {
+	case http.MethodGet:
+		list, err := a.store.ListDomainClaims()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("cannot list domain claims: %v", err), http.StatusInternalServerError)
+			return
+		}
+		res := make([]DomainClaim, 0, len(list))
+		for _, c := range list {
+			res = append(res, domainClaimJSON(c))
+		}
+		sendDomainJSON(w, res, opts)
+	case http.MethodPost:
+		// fetches from the domain: admin only
+		if !a.authorized(w, r) {
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("bad request: %v", err), http.StatusBadRequest)
+			return
+		}
+		var req AddDomainClaim
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error decoding JSON: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		idenPub, err := parseIdentityKey(req.Identity)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name, domain, err := domains.ParseAddress(req.Address)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		claim, err := a.verifyDomainClaim(name+"@"+domain, idenPub)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("cannot store domain claim: %v", err), http.StatusInternalServerError)
+			return
+		}
+		sendDomainJSON(w, domainClaimJSON(claim), opts)
+	default:
+		options(w, r, opts)
+	}
+}
+
+// domainClaim removes a domain claim: DELETE /domains/{address}
+func (a *WebAPI) domainClaim(w http.ResponseWriter, r *http.Request) {
+	opts := "DELETE, OPTIONS"
+	if r.Method != http.MethodDelete {
+		options(w, r, opts)
+		return
+	}
+	if !a.authorized(w, r) {
+		return
+	}
+	address := strings.ToLower(strings.TrimPrefix(r.URL.Path, "/domains/"))
+	err := a.store.RemoveDomainClaim(address)
+	if err != nil {
+		if spec.IsNotFoundError(err) {
+			http.Error(w, "domain claim not found", http.StatusNotFound)
+		} else {
+			http.Error(w, fmt.Sprintf("cannot remove domain claim: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Allow", opts)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getDogeID serves our own dogeid.json, so a domain that proxies this
+// path to us verifies our local identity: GET /.well-known/dogeid.json
+func (a *WebAPI) getDogeID(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, OPTIONS"
+	if r.Method != http.MethodGet {
+		options(w, r, opts)
+		return
+	}
+	doc := domains.Document{Names: map[string]string{}}
+	pro, err := a.store.GetProfile()
+	if err != nil && !spec.IsNotFoundError(err) {
+		http.Error(w, fmt.Sprintf("cannot load profile: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if handle := domains.Handle(pro.Name); err == nil && handle != "" {
+		if name := strings.ToLower(r.URL.Query().Get("name")); name == "" || name == handle {
+			doc.Names[handle] = hex.EncodeToString(a.idenKey.Pub[:])
+		}
+	}
+	// any site may verify us
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	sendDomainJSON(w, doc, opts)
+}
+
+// verifyDomainClaim fetches the domain's dogeid.json and stores the result.
+func (a *WebAPI) verifyDomainClaim(address string, idenPub []byte) (spec.DomainClaim, error) {
+	claim := spec.DomainClaim{Address: address, PubKey: idenPub, Verified: true, Checked: time.Now().Unix()}
+	err := a.verifier.Verify(a.Context, address, idenPub)
+	if err != nil {
+		claim.Verified = false
+		claim.Error = err.Error()
+	}
+	log.Printf("[web] verified domain claim %v: %v (%v)", address, claim.Verified, claim.Error)
+	return claim, a.store.SetDomainClaim(claim)
+}
+
+// verifiedDomain returns the identity's verified address ("" if none);
+// claims older than domains.CacheTime are re-verified in the background,
+// and keep their last result until the refresh replaces it.
+func (a *WebAPI) verifiedDomain(idenPub []byte) string {
+	claims, err := a.store.GetDomainClaims(idenPub)
+	if err != nil {
+		log.Printf("[web] cannot load domain claims: %v", err)
+		return ""
+	}
+	address := ""
+	for _, c := range claims {
+		if time.Since(time.Unix(c.Checked, 0)) > domains.CacheTime {
+			a.refreshDomainClaim(c)
+		}
+		if c.Verified && address == "" {
+			address = c.Address
+		}
+	}
+	return address
+}
+
+func (a *WebAPI) refreshDomainClaim(c spec.DomainClaim) {
+	a.refreshMu.Lock()
+	defer a.refreshMu.Unlock()
+	if a.refreshing[c.Address] {
+		return
+	}
+	a.refreshing[c.Address] = true
+	go func() {
+		_, err := a.verifyDomainClaim(c.Address, c.PubKey)
+		if err != nil {
+			log.Printf("[web] cannot store domain claim: %v", err)
+		}
+		a.refreshMu.Lock()
+		delete(a.refreshing, c.Address)
+		a.refreshMu.Unlock()
+	}()
+}
+
+func domainClaimJSON(c spec.DomainClaim) DomainClaim {
+	return DomainClaim{Address: c.Address, Identity: hex.EncodeToString(c.PubKey), Verified: c.Verified, Checked: c.Checked, Error: c.Error}
+}
+
+func sendDomainJSON(w http.ResponseWriter, res any, opts string) {
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
@@ -0,0 +1,272 @@
+package web
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"code.dogecoin.org/gossip/dnet"
+	"code.dogecoin.org/identity/internal/endorse"
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// Endorsement is one identity vouching for (or revoking) another.
+type Endorsement struct {
+	Endorser string `json:"endorser"` // endorsing identity pubkey (hex)
+	Subject  string `json:"subject"`  // endorsed identity pubkey (hex)
+	Revoked  bool   `json:"revoked"`  // endorsement has been withdrawn
+	Signed   int64  `json:"signed"`   // unix time signed
+}
+
+// Endorsements is the response to GET /endorsements/{hex}
+type Endorsements struct {
+	Received []Endorsement `json:"received"` // endorsements of the identity
+	Given    []Endorsement `json:"given"`    // endorsements made by the identity
+}
+
+// Endorse is the request body for POST /endorse
+type Endorse struct {
+	Identity string `json:"identity"` // identity pubkey (hex)
+	Revoke   bool   `json:"revoke"`   // withdraw an earlier endorsement
+}
+
+// Trust is the trust score of an identity relative to our identity
+// and pinned contacts (see endorse.Scores)
+type Trust struct {
+	Identity  string  `json:"identity"`  // identity pubkey (hex)
+	Score     float64 `json:"score"`     // [0, 1]: 0 if not reachable
+	Depth     int     `json:"depth"`     // endorsement hops from us or a contact (-1 if not reachable)
+	Endorsers int     `json:"endorsers"` // number of active endorsements received
+}
+
+const DefaultTrustLimit = 100
+
+// postEndorse signs an endorsement with our identity key:
+// POST /endorse {"identity":hex,"revoke":false}
+func (a *WebAPI) postEndorse(w http.ResponseWriter, r *http.Request) {
+	opts := "POST, OPTIONS"
+	if r.Method != http.MethodPost {
+		options(w, r, opts)
+		return
+	}
+	if !a.authorized(w, r) {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("bad request: %v", err), http.StatusBadRequest)
+		return
+	}
+	var req Endorse
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error decoding JSON: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	subject, err := parseIdentityKey(req.Identity)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if bytes.Equal(subject, a.idenKey.Pub[:]) {
+		http.Error(w, "cannot endorse our own identity", http.StatusBadRequest)
+		return
+	}
+
+	msg := endorse.EndorseMsg{
+		Time:    dnet.DogeNow(),
+		Subject: subject,
+		Level:   endorse.LevelVouch,
+	}
+	if req.Revoke {
+		msg.Level = endorse.LevelRevoked
+	}
+	payload := msg.Encode()
+	view := endorse.SignEndorsement(a.idenKey, payload)
+	end := spec.Endorsement{
+		Endorser: a.idenKey.Pub[:],
+		Subject:  subject,
+		Level:    int(msg.Level),
+		Payload:  payload,
+		Sig:      view.Signature()[:],
+		Time:     msg.Time.Local().Unix(),
+	}
+	err = a.store.SetEndorsement(end)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot store endorsement: %v", err), http.StatusInternalServerError)
+		return
+	}
+	// gossip it now; otherwise it goes out with random gossip later
+	select {
+	case a.outgoing <- dnet.RawMessage{Header: view.Header(), Payload: payload}:
+	default:
+		log.Printf("[web] outgoing queue is full: endorsement will be gossiped later")
+	}
+
+	bytes, err := json.Marshal(endorsementJSON(end))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
+
+// getEndorsements lists endorsements of and by an identity:
+// GET /endorsements/{hex}
+func (a *WebAPI) getEndorsements(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, OPTIONS"
+	if r.Method != http.MethodGet {
+		options(w, r, opts)
+		return
+	}
+	idenPub, err := parseIdentityKey(strings.TrimPrefix(r.URL.Path, "/endorsements/"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	of, err := a.store.GetEndorsementsOf(idenPub)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot load endorsements: %v", err), http.StatusInternalServerError)
+		return
+	}
+	by, err := a.store.GetEndorsementsBy(idenPub)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot load endorsements: %v", err), http.StatusInternalServerError)
+		return
+	}
+	res := Endorsements{
+		Received: make([]Endorsement, 0, len(of)),
+		Given:    make([]Endorsement, 0, len(by)),
+	}
+	for _, e := range of {
+		res.Received = append(res.Received, endorsementJSON(e))
+	}
+	for _, e := range by {
+		res.Given = append(res.Given, endorsementJSON(e))
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
+
+// getTrust reports trust scores relative to our identity and contacts:
+// GET /trust?limit=N (most trusted first), GET /trust/{hex}
+func (a *WebAPI) getTrust(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, OPTIONS"
+	if r.Method != http.MethodGet {
+		options(w, r, opts)
+		return
+	}
+	var idenPub []byte
+	limit := DefaultTrustLimit
+	if arg := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/trust"), "/"); arg != "" {
+		pub, err := parseIdentityKey(arg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		idenPub = pub
+	} else if arg := r.URL.Query().Get("limit"); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid limit: expecting a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	scores, err := a.trustScores()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot compute trust: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var res any
+	if idenPub != nil {
+		trust := Trust{Identity: hex.EncodeToString(idenPub), Depth: -1}
+		if s, ok := scores[string(idenPub)]; ok {
+			trust.Score, trust.Depth, trust.Endorsers = s.Value, s.Depth, s.Endorsers
+		}
+		res = trust
+	} else {
+		list := make([]Trust, 0, len(scores))
+		for id, s := range scores {
+			list = append(list, Trust{Identity: hex.EncodeToString([]byte(id)), Score: s.Value, Depth: s.Depth, Endorsers: s.Endorsers})
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Score != list[j].Score {
+				return list[i].Score > list[j].Score
+			}
+			return list[i].Identity < list[j].Identity
+		})
+		if len(list) > limit {
+			list = list[:limit]
+		}
+		res = list
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
+
+// trustScores seeds trust with our identity and pinned contacts.
+func (a *WebAPI) trustScores() (map[string]endorse.Score, error) {
+	contacts, err := a.store.ListContacts()
+	if err != nil {
+		return nil, err
+	}
+	seeds := [][]byte{a.idenKey.Pub[:]}
+	for _, c := range contacts {
+		seeds = append(seeds, c.PubKey)
+	}
+	list, err := a.store.ListEndorsements()
+	if err != nil {
+		return nil, err
+	}
+	// endorsements by blocked identities don't count
+	blocked := make(map[string]bool)
+	active := list[:0]
+	for _, e := range list {
+		isBlocked, seen := blocked[string(e.Endorser)]
+		if !seen {
+			isBlocked = a.policy.SignerBlocked(a.store, e.Endorser) != ""
+			blocked[string(e.Endorser)] = isBlocked
+		}
+		if !isBlocked {
+			active = append(active, e)
+		}
+	}
+	return endorse.Scores(seeds, active), nil
+}
+
+func endorsementJSON(e spec.Endorsement) Endorsement {
+	return Endorsement{
+		Endorser: hex.EncodeToString(e.Endorser),
+		Subject:  hex.EncodeToString(e.Subject),
+		Revoked:  e.Level == endorse.LevelRevoked,
+		Signed:   e.Time,
+	}
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+const EventsBatchSize = 100
+const EventsKeepAlive = 30 * time.Second
+
+// Event is the JSON data of a Server-Sent Event in the /events stream.
+type Event struct {
+	ID       int64  `json:"id"`       // event id (send as Last-Event-ID to resume)
+	Kind     string `json:"kind"`     // "new", "updated", "expired" or "revoked"
+	Identity string `json:"identity"` // identity pubkey hex
+	Time     int64  `json:"time"`     // unix time of the change
+}
+
+// getEvents streams identity changes as Server-Sent Events.
+// Clients resume after the Last-Event-ID header (or ?since=<id>),
+// otherwise the stream starts with the next change.
+func (a *WebAPI) getEvents(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, OPTIONS"
+	if r.Method != http.MethodGet {
+		options(w, r, opts)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	var last int64
+	since := r.Header.Get("Last-Event-ID")
+	if since == "" {
+		since = r.URL.Query().Get("since")
+	}
+	if since != "" {
+		id, err := strconv.ParseInt(since, 10, 64)
+		if err != nil || id < 0 {
+			http.Error(w, fmt.Sprintf("invalid event id: %v", since), http.StatusBadRequest)
+			return
+		}
+		last = id
+	} else {
+		id, err := a.store.LatestChange()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("cannot load changes: %v", err), http.StatusInternalServerError)
+			return
+		}
+		last = id
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Allow", opts)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(EventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		// must get the channel before querying, to avoid missing a change
+		wake := a._store.Changed()
+		changes, err := a.store.GetChanges(last, EventsBatchSize)
+		if err != nil {
+			log.Printf("[web] cannot load changes: %v", err)
+			return
+		}
+		for _, c := range changes {
+			err = writeEvent(w, c)
+			if err != nil {
+				return // client went away
+			}
+			last = c.ID
+		}
+		flusher.Flush()
+		if len(changes) == EventsBatchSize {
+			continue // more to send
+		}
+		select {
+		case <-wake:
+		case <-keepAlive.C:
+			_, err = w.Write([]byte(": keep-alive\n\n"))
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-a.Context.Done():
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, c spec.Change) error {
+	data, err := json.Marshal(Event{
+		ID:       c.ID,
+		Kind:     c.Kind,
+		Identity: hex.EncodeToString(c.PubKey),
+		Time:     c.Time,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", c.ID, c.Kind, data)
+	return err
+}
@@ -0,0 +1,364 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.dogecoin.org/gossip/iden"
+	"code.dogecoin.org/identity/internal/did"
+	"code.dogecoin.org/identity/internal/dogeicon"
+	"code.dogecoin.org/identity/internal/profile"
+	"code.dogecoin.org/identity/internal/spec"
+	"code.dogecoin.org/identity/internal/vcard"
+)
+
+// Export identities for address books (vCard 4.0) and linked-data tools
+// (schema.org Person as JSON-LD): GET /export/vcard/{hex} and
+// GET /export/jsonld/{hex} export one identity, GET /export/vcard and
+// GET /export/jsonld export all pinned contacts (admin only).
+
+const SchemaOrg = "https://schema.org"
+const JSONLDContentType = "application/ld+json"
+
+// Person is a schema.org Person.
+type Person struct {
+	Context       string        `json:"@context,omitempty"`
+	Type          string        `json:"@type"`
+	ID            string        `json:"@id"` // did:doge:<hex>
+	Name          string        `json:"name"`
+	AlternateName string        `json:"alternateName,omitempty"` // our pinned contact name
+	Description   string        `json:"description,omitempty"`   // bio
+	Image         string        `json:"image,omitempty"`         // icon as a PNG data URL
+	Identifier    PropertyValue `json:"identifier"`
+	HomeLocation  *Place        `json:"homeLocation,omitempty"`
+	DateModified  string        `json:"dateModified,omitempty"` // when the identity was signed
+}
+
+// PersonGraph is a list of Persons (all pinned contacts)
+type PersonGraph struct {
+	Context string   `json:"@context"`
+	Graph   []Person `json:"@graph"`
+}
+
+type PropertyValue struct {
+	Type       string `json:"@type"`
+	PropertyID string `json:"propertyID"`
+	Value      string `json:"value"`
+}
+
+type Place struct {
+	Type    string          `json:"@type"`
+	Geo     *GeoCoordinates `json:"geo,omitempty"`
+	Address *PostalAddress  `json:"address,omitempty"`
+}
+
+type GeoCoordinates struct {
+	Type      string  `json:"@type"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type PostalAddress struct {
+	Type            string `json:"@type"`
+	AddressLocality string `json:"addressLocality,omitempty"`
+	AddressCountry  string `json:"addressCountry,omitempty"`
+}
+
+// exported is an identity to export.
+type exported struct {
+	pub     []byte
+	msg     *iden.IdentityMsg // nil if not stored (a pinned contact)
+	signed  int64
+	pinned  string // pinned contact name
+	iconPNG []byte
+}
+
+// exportVCard: GET /export/vcard[/{hex}]
+func (a *WebAPI) exportVCard(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, OPTIONS"
+	if r.Method != http.MethodGet {
+		options(w, r, opts)
+		return
+	}
+	list, filename, ok := a.exportList(w, r, "/export/vcard")
+	if !ok {
+		return
+	}
+	cards := make([]vcard.Card, 0, len(list))
+	for _, e := range list {
+		cards = append(cards, e.card())
+	}
+	body := vcard.Encode(cards...)
+	w.Header().Set("Content-Type", vcard.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%v.vcf"`, filename))
+	w.Header().Set("Allow", opts)
+	w.Write(body)
+}
+
+// exportJSONLD: GET /export/jsonld[/{hex}]
+func (a *WebAPI) exportJSONLD(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, OPTIONS"
+	if r.Method != http.MethodGet {
+		options(w, r, opts)
+		return
+	}
+	list, _, ok := a.exportList(w, r, "/export/jsonld")
+	if !ok {
+		return
+	}
+	var res any
+	if strings.HasPrefix(r.URL.Path, "/export/jsonld/") {
+		person := list[0].person()
+		person.Context = SchemaOrg
+		res = person
+	} else {
+		graph := PersonGraph{Context: SchemaOrg, Graph: make([]Person, 0, len(list))}
+		for _, e := range list {
+			graph.Graph = append(graph.Graph, e.person())
+		}
+		res = graph
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", JSONLDContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
+
+// exportList loads the identity named in the path, or all pinned contacts.
+func (a *WebAPI) exportList(w http.ResponseWriter, r *http.Request, prefix string) (list []exported, filename string, ok bool) {
+	if arg := strings.TrimPrefix(r.URL.Path, prefix+"/"); arg != r.URL.Path && arg != "" {
+		idenPub, err := parseIdentityKey(arg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, "", false
+		}
+		e, err := a.loadExported(idenPub)
+		if err == nil && e.msg == nil {
+			err = spec.ErrNotFound
+		}
+		if err != nil {
+			if spec.IsNotFoundError(err) {
+				http.Error(w, "identity not found", http.StatusNotFound)
+			} else {
+				http.Error(w, fmt.Sprintf("cannot load identity: %v", err), http.StatusInternalServerError)
+			}
+			return nil, "", false
+		}
+		return []exported{e}, safeFilename(e.msg.Name, "identity"), true
+	}
+	// contacts and their pinned names are private
+	if !a.authorized(w, r) {
+		return nil, "", false
+	}
+	contacts, err := a.store.ListContacts()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot list contacts: %v", err), http.StatusInternalServerError)
+		return nil, "", false
+	}
+	for _, c := range contacts {
+		e, err := a.loadExported(c.PubKey)
+		if err != nil && !spec.IsNotFoundError(err) {
+			http.Error(w, fmt.Sprintf("cannot load identity: %v", err), http.StatusInternalServerError)
+			return nil, "", false
+		}
+		e.pinned = c.Name
+		list = append(list, e) // exported with the pinned name if not stored
+	}
+	return list, "contacts", true
+}
+
+// loadExported loads a stored identity (msg is nil if not stored or blocked).
+func (a *WebAPI) loadExported(idenPub []byte) (exported, error) {
+	e := exported{pub: idenPub}
+	payload, _, signed, err := a.store.GetIdentity(idenPub)
+	if err != nil {
+		return e, err
+	}
+	msg, ok := profile.DecodeIdentity(payload)
+	if !ok || a.blocked(idenPub, &msg) {
+		return e, nil
+	}
+	e.msg, e.signed = &msg, signed
+	if img, err := dogeicon.Decode(msg.Icon); err == nil {
+		var buf bytes.Buffer
+		if png.Encode(&buf, img) == nil {
+			e.iconPNG = buf.Bytes()
+		}
+	}
+	return e, nil
+}
+
+func (e exported) card() vcard.Card {
+	c := vcard.Card{UID: did.FromPubKey(e.pub), FN: e.pinned}
+	if e.msg != nil {
+		c.FN = e.msg.Name
+		if e.pinned != e.msg.Name {
+			c.Nickname = e.pinned
+		}
+		c.Note = e.msg.Bio
+		c.Locality = e.msg.City
+		c.Country = strings.TrimRight(e.msg.Country, "\x00")
+		if e.msg.Lat != 0 || e.msg.Long != 0 { // 0,0 is no location
+			c.HasGeo = true
+			c.Lat = float64(e.msg.Lat) / 10.0  // undo quantization
+			c.Lon = float64(e.msg.Long) / 10.0 // undo quantization
+		}
+		c.Photo, c.PhotoExt = e.iconPNG, "png"
+		c.Rev = time.Unix(e.signed, 0)
+	}
+	return c
+}
+
+func (e exported) person() Person {
+	p := Person{
+		Type:       "Person",
+		ID:         did.FromPubKey(e.pub),
+		Name:       e.pinned,
+		Identifier: PropertyValue{Type: "PropertyValue", PropertyID: "did:doge", Value: did.FromPubKey(e.pub)},
+	}
+	if e.msg != nil {
+		p.Name = e.msg.Name
+		if e.pinned != e.msg.Name {
+			p.AlternateName = e.pinned
+		}
+		p.Description = e.msg.Bio
+		if len(e.iconPNG) > 0 {
+			p.Image = "data:image/png;base64," + base64.StdEncoding.EncodeToString(e.iconPNG)
+		}
+		place := &Place{Type: "Place"}
+		if e.msg.Lat != 0 || e.msg.Long != 0 { // 0,0 is no location
+			place.Geo = &GeoCoordinates{
+				Type:      "GeoCoordinates",
+				Latitude:  float64(e.msg.Lat) / 10.0,  // undo quantization
+				Longitude: float64(e.msg.Long) / 10.0, // undo quantization
+			}
+		}
+		country := strings.TrimRight(e.msg.Country, "\x00")
+		if e.msg.City != "" || country != "" {
+			place.Address = &PostalAddress{Type: "PostalAddress", AddressLocality: e.msg.City, AddressCountry: country}
+		}
+		if place.Geo != nil || place.Address != nil {
+			p.HomeLocation = place
+		}
+		p.DateModified = time.Unix(e.signed, 0).UTC().Format(time.RFC3339)
+	}
+	return p
+}
+
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func safeFilename(name string, fallback string) string {
+	name = strings.Trim(unsafeFilename.ReplaceAllString(name, "-"), "-.")
+	if name == "" {
+		return fallback
+	}
+	return name
+}
+
+// postProfileVCard imports a vCard into our profile: POST /profile/vcard
+// (fields missing from the card keep their current values)
+func (a *WebAPI) postProfileVCard(w http.ResponseWriter, r *http.Request) {
+	opts := "POST, OPTIONS"
+	if r.Method != http.MethodPost {
+		options(w, r, opts)
+		return
+	}
+	if !a.authorized(w, r) {
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, a.settings.Limits().MaxImport))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("bad request: %v", err), http.StatusBadRequest)
+		return
+	}
+	cards, err := vcard.Decode(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(cards) != 1 {
+		http.Error(w, fmt.Sprintf("invalid vCard: expecting one card (got %v)", len(cards)), http.StatusBadRequest)
+		return
+	}
+	card := cards[0]
+	current, err := a.store.GetProfile()
+	if err != nil && !spec.IsNotFoundError(err) {
+		http.Error(w, fmt.Sprintf("cannot load profile: %v", err), http.StatusInternalServerError)
+		return
+	}
+	to := NewIdentFromProfile(&current)
+	to.Long = 0 // deprecated
+	if card.FN != "" {
+		to.Name = card.FN
+	}
+	if card.Note != "" {
+		to.Bio = card.Note
+	}
+	if card.Locality != "" {
+		to.City = card.Locality
+	}
+	if card.Country != "" {
+		to.Country = card.Country
+		if !profile.IsCountry(strings.ToUpper(card.Country)) {
+			to.Country = "" // a country name, not an ISO code
+		}
+	}
+	if card.HasGeo {
+		to.Lat, to.Lon = card.Lat, card.Lon
+	}
+	pro, err := ValidateProfile(to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(card.Photo) > 0 {
+		conf, _, err := image.DecodeConfig(bytes.NewReader(card.Photo))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid PHOTO: expecting PNG or JPEG: %v", err), http.StatusBadRequest)
+			return
+		}
+		if conf.Width > MaxIconUploadDim || conf.Height > MaxIconUploadDim {
+			http.Error(w, fmt.Sprintf("invalid PHOTO: larger than %vx%v (got %vx%v)", MaxIconUploadDim, MaxIconUploadDim, conf.Width, conf.Height), http.StatusBadRequest)
+			return
+		}
+		img, _, err := image.Decode(bytes.NewReader(card.Photo))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid PHOTO: %v", err), http.StatusBadRequest)
+			return
+		}
+		pro.Icon, err = dogeicon.Encode(img, dogeicon.StyleAuto)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid PHOTO: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	err = a.store.SetProfile(pro)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot store profile: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[web] imported profile from vCard: %v", pro.Name)
+
+	// sign and announce the new profile
+	a.announceChanges <- pro
+
+	sendProfile(w, &pro, opts)
+}
@@ -0,0 +1,172 @@
+package web
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"code.dogecoin.org/identity/internal/profile"
+)
+
+const GeoJSONPageSize = 200
+
+// Feature is a GeoJSON Feature for one identity.
+type Feature struct {
+	Type       string            `json:"type"`       // "Feature"
+	ID         string            `json:"id"`         // identity pubkey hex
+	Geometry   *Point            `json:"geometry"`   // null if the identity has no location
+	Properties FeatureProperties `json:"properties"` // profile summary
+}
+
+// Point is a GeoJSON Point geometry.
+type Point struct {
+	Type        string     `json:"type"`        // "Point"
+	Coordinates [2]float64 `json:"coordinates"` // [lon, lat] WGS84
+}
+
+// FeatureProperties are the profile fields included in a Feature.
+type FeatureProperties struct {
+	Name     string `json:"name"`     // [30] display name
+	Country  string `json:"country"`  // [2] ISO 3166-1 alpha-2 code
+	City     string `json:"city"`     // [30] city name
+	Nodes    int    `json:"nodes"`    // number of nodes claimed by this identity
+	Verified bool   `json:"verified"` // a node also claims this identity
+}
+
+// BBox is a bounding box in degrees; MinLon > MaxLon crosses the antimeridian.
+type BBox struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+}
+
+func (b *BBox) Contains(lat float64, lon float64) bool {
+	if lat < b.MinLat || lat > b.MaxLat {
+		return false
+	}
+	if b.MinLon <= b.MaxLon {
+		return lon >= b.MinLon && lon <= b.MaxLon
+	}
+	return lon >= b.MinLon || lon <= b.MaxLon // crosses the antimeridian
+}
+
+// parseBBox parses "minLon,minLat,maxLon,maxLat" (the GeoJSON bbox order)
+func parseBBox(arg string) (*BBox, error) {
+	parts := strings.Split(arg, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid bbox: expecting minLon,minLat,maxLon,maxLat (got %v)", arg)
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bbox: %v", err)
+		}
+		v[i] = f
+	}
+	b := &BBox{MinLon: v[0], MinLat: v[1], MaxLon: v[2], MaxLat: v[3]}
+	if b.MinLat < minLat || b.MaxLat > maxLat || b.MinLat > b.MaxLat {
+		return nil, fmt.Errorf("invalid bbox: latitude out of range [%v, %v] (got %v, %v)", minLat, maxLat, b.MinLat, b.MaxLat)
+	}
+	if b.MinLon < minLon || b.MaxLon > maxLon {
+		return nil, fmt.Errorf("invalid bbox: longitude out of range [%v, %v] (got %v, %v)", minLon, maxLon, b.MinLon, b.MaxLon)
+	}
+	return b, nil
+}
+
+// getGeoJSON streams a FeatureCollection of all stored identities:
+// /geojson?bbox=minLon,minLat,maxLon,maxLat&country=AU,NZ
+func (a *WebAPI) getGeoJSON(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, OPTIONS"
+	if r.Method != http.MethodGet {
+		options(w, r, opts)
+		return
+	}
+	var bbox *BBox
+	if arg := r.URL.Query().Get("bbox"); arg != "" {
+		b, err := parseBBox(arg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		bbox = b
+	}
+	countries := map[string]bool{}
+	if arg := r.URL.Query().Get("country"); arg != "" {
+		for _, c := range strings.Split(arg, ",") {
+			countries[strings.ToUpper(strings.TrimSpace(c))] = true
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Allow", opts)
+	flusher, _ := w.(http.Flusher)
+	_, err := w.Write([]byte(`{"type":"FeatureCollection","features":[`))
+	if err != nil {
+		return
+	}
+	// page through the store, so the database isn't held while writing
+	sep := ""
+	var after []byte
+	for {
+		list, err := a.store.ListIdentities(after, GeoJSONPageSize)
+		if err != nil {
+			// too late for an HTTP error; truncate the output (invalid JSON)
+			log.Printf("[web] cannot list identities: %v", err)
+			return
+		}
+		for _, id := range list {
+			after = id.PubKey
+			pro, ok := profile.DecodeIdentity(id.Payload)
+			if !ok || a.blocked(id.PubKey, &pro) {
+				continue
+			}
+			if len(countries) > 0 && !countries[pro.Country] {
+				continue
+			}
+			lat := float64(pro.Lat) / 10.0  // undo quantization
+			lon := float64(pro.Long) / 10.0 // undo quantization
+			var geom *Point
+			if pro.Lat != 0 || pro.Long != 0 {
+				geom = &Point{Type: "Point", Coordinates: [2]float64{lon, lat}}
+			}
+			if bbox != nil && (geom == nil || !bbox.Contains(lat, lon)) {
+				continue
+			}
+			feature, err := json.Marshal(Feature{
+				Type:     "Feature",
+				ID:       hex.EncodeToString(id.PubKey),
+				Geometry: geom,
+				Properties: FeatureProperties{
+					Name:     pro.Name,
+					Country:  pro.Country,
+					City:     pro.City,
+					Nodes:    len(pro.Nodes),
+					Verified: a.identityVerified(id.PubKey, pro.Nodes),
+				},
+			})
+			if err != nil {
+				log.Printf("[web] error encoding JSON: %v", err)
+				return
+			}
+			_, err = w.Write([]byte(sep))
+			if err == nil {
+				_, err = w.Write(feature)
+			}
+			if err != nil {
+				return // client went away
+			}
+			sep = ","
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if len(list) < GeoJSONPageSize {
+			break
+		}
+	}
+	w.Write([]byte("]}"))
+}
@@ -0,0 +1,94 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Health is the JSON reply from /healthz and /readyz.
+type Health struct {
+	Status string           `json:"status"` // "ok" or "fail"
+	Checks map[string]Check `json:"checks"` // individual checks by name
+}
+
+// Check is the result of one health check.
+type Check struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// getHealthz reports whether the process and database are alive.
+func (a *WebAPI) getHealthz(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, OPTIONS"
+	if r.Method != http.MethodGet {
+		options(w, r, opts)
+		return
+	}
+	// use the request context, so a locked database cannot hang the probe
+	store := a._store.WithCtx(r.Context())
+	checks := map[string]Check{}
+	checks["database"] = errCheck(store.Ping(), "readable")
+	a.writeHealth(w, checks, opts)
+}
+
+// getReadyz reports whether the service is doing its job: connected to
+// dogenet, gossiping a current announcement, and able to store identities.
+func (a *WebAPI) getReadyz(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, OPTIONS"
+	if r.Method != http.MethodGet {
+		options(w, r, opts)
+		return
+	}
+	store := a._store.WithCtx(r.Context())
+	status := a.status.Get()
+	now := time.Now().Unix()
+	checks := map[string]Check{}
+	if status.Connected {
+		checks["dogenet"] = Check{OK: true, Detail: "handshake complete"}
+	} else {
+		checks["dogenet"] = Check{OK: false, Detail: "not connected"}
+	}
+	switch {
+	case status.AnnounceSigned == 0:
+		checks["announce"] = Check{OK: false, Detail: "no announcement signed (set a profile and connect to dogenet)"}
+	case status.AnnounceExpiry <= now:
+		checks["announce"] = Check{OK: false, Detail: fmt.Sprintf("announcement expired %v seconds ago", now-status.AnnounceExpiry)}
+	default:
+		checks["announce"] = Check{OK: true, Detail: fmt.Sprintf("signed %v seconds ago, expires in %v seconds", now-status.AnnounceSigned, status.AnnounceExpiry-now)}
+	}
+	checks["database"] = errCheck(store.CheckWritable(), "writable")
+	a.writeHealth(w, checks, opts)
+}
+
+func errCheck(err error, okDetail string) Check {
+	if err != nil {
+		return Check{OK: false, Detail: err.Error()}
+	}
+	return Check{OK: true, Detail: okDetail}
+}
+
+// writeHealth replies 200 if all checks pass, otherwise 503.
+func (a *WebAPI) writeHealth(w http.ResponseWriter, checks map[string]Check, opts string) {
+	res := Health{Status: "ok", Checks: checks}
+	code := http.StatusOK
+	for _, c := range checks {
+		if !c.OK {
+			res.Status = "fail"
+			code = http.StatusServiceUnavailable
+		}
+	}
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Allow", opts)
+	w.WriteHeader(code)
+	w.Write(bytes)
+}
@@ -0,0 +1,239 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register JPEG for image.Decode
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.dogecoin.org/identity/internal/dogeicon"
+	"code.dogecoin.org/identity/internal/profile"
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+const IconCacheMaxAge = 3600 // seconds; identities are re-signed daily at most
+
+// getIcon renders an identity's icon as PNG: /icon/{pubkey}.png?size=N&filter=nearest|bilinear
+func (a *WebAPI) getIcon(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, HEAD, OPTIONS"
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		options(w, r, opts)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/icon/")
+	if !strings.HasSuffix(name, ".png") {
+		http.NotFound(w, r)
+		return
+	}
+	idenPub, err := hex.DecodeString(strings.TrimSuffix(name, ".png"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid identity pubkey: %v", err), http.StatusBadRequest)
+		return
+	}
+	payload, _, signed, err := a.store.GetIdentity(idenPub)
+	if err != nil {
+		if spec.IsNotFoundError(err) {
+			http.Error(w, "identity not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("cannot load identity: %v", err), http.StatusInternalServerError)
+		return
+	}
+	pro, ok := profile.DecodeIdentity(payload)
+	if !ok {
+		http.Error(w, "invalid identity", http.StatusInternalServerError)
+		return
+	}
+	if a.blocked(idenPub, &pro) {
+		http.Error(w, "identity not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", IconCacheMaxAge))
+	sendIcon(w, r, pro.Icon, time.Unix(signed, 0), opts)
+}
+
+// getProfileIcon renders the local profile's icon as PNG: /profile/icon.png?size=N&filter=nearest|bilinear
+func (a *WebAPI) getProfileIcon(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, HEAD, OPTIONS"
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		options(w, r, opts)
+		return
+	}
+	pro, err := a.store.GetProfile()
+	if err != nil && !spec.IsNotFoundError(err) {
+		http.Error(w, fmt.Sprintf("cannot load profile: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "private, max-age=0, must-revalidate")
+	sendIcon(w, r, pro.Icon, time.Time{}, opts)
+}
+
+func sendIcon(w http.ResponseWriter, r *http.Request, comp []byte, modified time.Time, opts string) {
+	size := dogeicon.IconDim
+	if s := r.URL.Query().Get("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid size: %v", s), http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
+	filter := r.URL.Query().Get("filter")
+	img, err := dogeicon.Decode(comp)
+	if err != nil {
+		if err == dogeicon.ErrNoIcon {
+			http.Error(w, "no icon", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	img, err = dogeicon.Scale(img, size, filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var buf bytes.Buffer
+	err = png.Encode(&buf, img)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding PNG: %v", err), http.StatusInternalServerError)
+		return
+	}
+	// the rendering depends only on the icon bytes and options
+	hash := sha256.Sum256(comp)
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%d-%s"`, hash[:8], size, filter))
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Allow", opts)
+	http.ServeContent(w, r, "", modified, bytes.NewReader(buf.Bytes()))
+}
+
+const MaxIconUploadDim = 4096 // pixels (width or height)
+
+// IconUpload is the result of encoding an uploaded image.
+type IconUpload struct {
+	Icon    string    `json:"icon"`              // [1585] compressed icon (base64-encoded)
+	Style   int       `json:"style"`             // style byte used to encode the icon
+	Preview string    `json:"preview"`           // PNG data URL of the decoded icon
+	Profile *NewIdent `json:"profile,omitempty"` // updated profile (omitted for preview)
+}
+
+// postProfileIcon encodes an uploaded PNG/JPEG as the profile icon:
+// POST /profile/icon?style=flat|linear|auto&preview=1 (body: image or multipart "icon")
+func (a *WebAPI) postProfileIcon(w http.ResponseWriter, r *http.Request) {
+	opts := "POST, OPTIONS"
+	if r.Method != http.MethodPost {
+		options(w, r, opts)
+		return
+	}
+	// previews need the token too: decoding large uploads is expensive
+	if !a.authorized(w, r) {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, a.settings.Limits().MaxIconUpload)
+	style := dogeicon.StyleAuto
+	switch r.URL.Query().Get("style") {
+	case "flat":
+		style = dogeicon.StyleFlat
+	case "linear":
+		style = dogeicon.StyleLinear
+	case "auto", "":
+	default:
+		http.Error(w, fmt.Sprintf("invalid style: expecting flat, linear or auto (got %v)", r.URL.Query().Get("style")), http.StatusBadRequest)
+		return
+	}
+	preview := r.URL.Query().Get("preview") == "1"
+
+	// accept a multipart form upload or the raw image as the body
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("icon")
+		if err != nil {
+			http.Error(w, fmt.Sprintf("bad request: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("bad request: %v", err), http.StatusBadRequest)
+		return
+	}
+	conf, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid image: expecting PNG or JPEG: %v", err), http.StatusBadRequest)
+		return
+	}
+	if conf.Width > MaxIconUploadDim || conf.Height > MaxIconUploadDim {
+		http.Error(w, fmt.Sprintf("invalid image: larger than %vx%v (got %vx%v)", MaxIconUploadDim, MaxIconUploadDim, conf.Width, conf.Height), http.StatusBadRequest)
+		return
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid image: %v", err), http.StatusBadRequest)
+		return
+	}
+	comp, err := dogeicon.Encode(img, style)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// render the preview from the compressed icon (what peers will see)
+	decoded, err := dogeicon.Decode(comp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	err = png.Encode(&buf, decoded)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding PNG: %v", err), http.StatusInternalServerError)
+		return
+	}
+	res := IconUpload{
+		Icon:    base64.StdEncoding.EncodeToString(comp),
+		Style:   int(comp[0]),
+		Preview: "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}
+
+	if !preview {
+		pro, err := a.store.GetProfile()
+		if err != nil && !spec.IsNotFoundError(err) {
+			http.Error(w, fmt.Sprintf("cannot load profile: %v", err), http.StatusInternalServerError)
+			return
+		}
+		pro.Icon = comp
+		err = a.store.SetProfile(pro)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("cannot store profile: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		// sign and announce the new profile
+		a.announceChanges <- pro
+
+		newIdent := NewIdentFromProfile(&pro)
+		res.Profile = &newIdent
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
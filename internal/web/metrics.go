@@ -0,0 +1,42 @@
+package web
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"code.dogecoin.org/identity/internal/metrics"
+)
+
+// timed records the latency of each request to a route.
+func timed(route string, handler http.Handler) http.Handler {
+	latency := metrics.HTTPDuration.With(route)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		handler.ServeHTTP(w, r)
+		latency.Observe(time.Since(start).Seconds())
+	})
+}
+
+// getMetrics serves Prometheus metrics in the text exposition format.
+func (a *WebAPI) getMetrics(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, OPTIONS"
+	if r.Method != http.MethodGet {
+		options(w, r, opts)
+		return
+	}
+	count, err := a.store.CountIdentities()
+	if err != nil {
+		log.Printf("[web] cannot count identities: %v", err)
+	} else {
+		metrics.IdentityCount.Set(float64(count))
+	}
+	var buf bytes.Buffer
+	metrics.WriteText(&buf)
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Header().Set("Allow", opts)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"code.dogecoin.org/identity/internal/profile"
+)
+
+const NearbyDefaultLimit = 100
+const NearbyMaxKm = 20038 // half the equator: covers the whole globe
+
+// Nearby is a Profile found near a location.
+type Nearby struct {
+	Identity string  `json:"identity"` // identity pubkey hex
+	Distance float64 `json:"distance"` // great-circle distance (km)
+	Profile  Profile `json:"profile"`  // gossiped profile
+}
+
+// getNearby finds identities near a location, nearest first:
+// /nearby?lat=<deg>&lon=<deg>&km=<radius>&limit=<n>
+func (a *WebAPI) getNearby(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, OPTIONS"
+	if r.Method != http.MethodGet {
+		options(w, r, opts)
+		return
+	}
+	query := r.URL.Query()
+	lat, err := strconv.ParseFloat(query.Get("lat"), 64)
+	if err != nil || lat < minLat || lat > maxLat {
+		http.Error(w, fmt.Sprintf("invalid latitude: expecting [%v, %v] (got %v)", minLat, maxLat, query.Get("lat")), http.StatusBadRequest)
+		return
+	}
+	lon, err := strconv.ParseFloat(query.Get("lon"), 64)
+	if err != nil || lon < minLon || lon > maxLon {
+		http.Error(w, fmt.Sprintf("invalid longitude: expecting [%v, %v] (got %v)", minLon, maxLon, query.Get("lon")), http.StatusBadRequest)
+		return
+	}
+	km, err := strconv.ParseFloat(query.Get("km"), 64)
+	if err != nil || km <= 0 || math.IsNaN(km) {
+		http.Error(w, fmt.Sprintf("invalid km: expecting a positive radius (got %v)", query.Get("km")), http.StatusBadRequest)
+		return
+	}
+	if km > NearbyMaxKm {
+		km = NearbyMaxKm
+	}
+	maxLimit := a.settings.Limits().NearbyMaxLimit
+	limit := NearbyDefaultLimit
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	if arg := query.Get("limit"); arg != "" {
+		limit, err = strconv.Atoi(arg)
+		if err != nil || limit < 1 || limit > maxLimit {
+			http.Error(w, fmt.Sprintf("invalid limit: expecting [1, %v] (got %v)", maxLimit, arg), http.StatusBadRequest)
+			return
+		}
+	}
+
+	found, err := a.store.FindIdentitiesNear(lat, lon, km, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot search identities: %v", err), http.StatusInternalServerError)
+		return
+	}
+	res := make([]Nearby, 0, len(found))
+	for _, id := range found {
+		pro, ok := profile.DecodeIdentity(id.Payload)
+		if !ok || a.blocked(id.PubKey, &pro) {
+			continue
+		}
+		res = append(res, Nearby{
+			Identity: hex.EncodeToString(id.PubKey),
+			Distance: math.Round(id.Distance*10) / 10,
+			Profile:  a.identityProfile(id.PubKey, &pro, a.identityVerified(id.PubKey, pro.Nodes)),
+		})
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
@@ -0,0 +1,184 @@
+package web
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+const OneDay = 24 * 60 * 60 // seconds
+
+// ProfileNode is a node pubkey included in our announcement.
+type ProfileNode struct {
+	PubKey  string `json:"pubkey"`  // node pubkey (hex)
+	Seen    int64  `json:"seen"`    // unix time the node was last bound to our identity
+	Current bool   `json:"current"` // the dogenet node we are connected to
+}
+
+// AddNode is the request body for POST /profile/nodes
+type AddNode struct {
+	PubKey string `json:"pubkey"` // node pubkey (hex)
+}
+
+// ExpiredNodes is the reply from POST /profile/nodes/expire
+type ExpiredNodes struct {
+	Expired []string `json:"expired"` // removed node pubkeys (hex)
+}
+
+// profileNodes manages the profile's node list:
+// GET /profile/nodes, POST /profile/nodes {"pubkey":hex}
+func (a *WebAPI) profileNodes(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, POST, OPTIONS"
+	switch r.Method {
+	case http.MethodGet:
+		a.sendProfileNodes(w, opts)
+	case http.MethodPost:
+		if !a.authorized(w, r) {
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("bad request: %v", err), http.StatusBadRequest)
+			return
+		}
+		var req AddNode
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error decoding JSON: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		pub, err := parseNodeKey(req.PubKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = a.store.AddProfileNode(pub)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("cannot add profile node: %v", err), http.StatusInternalServerError)
+			return
+		}
+		a.announceChanges <- spec.NodesChangedMsg{}
+		a.sendProfileNodes(w, opts)
+	default:
+		options(w, r, opts)
+	}
+}
+
+// profileNode removes one node, or expires old nodes:
+// DELETE /profile/nodes/{hex}, POST /profile/nodes/expire?days=N
+func (a *WebAPI) profileNode(w http.ResponseWriter, r *http.Request) {
+	arg := strings.TrimPrefix(r.URL.Path, "/profile/nodes/")
+	if arg == "expire" {
+		a.expireProfileNodes(w, r)
+		return
+	}
+	opts := "DELETE, OPTIONS"
+	if r.Method != http.MethodDelete {
+		options(w, r, opts)
+		return
+	}
+	if !a.authorized(w, r) {
+		return
+	}
+	pub, err := parseNodeKey(arg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = a.store.RemoveProfileNode(pub)
+	if err != nil {
+		if spec.IsNotFoundError(err) {
+			http.Error(w, "node not found in profile", http.StatusNotFound)
+		} else {
+			http.Error(w, fmt.Sprintf("cannot remove profile node: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+	a.announceChanges <- spec.NodesChangedMsg{}
+	a.sendProfileNodes(w, opts)
+}
+
+// expireProfileNodes removes nodes not seen for N days,
+// except the node we are currently connected to.
+func (a *WebAPI) expireProfileNodes(w http.ResponseWriter, r *http.Request) {
+	opts := "POST, OPTIONS"
+	if r.Method != http.MethodPost {
+		options(w, r, opts)
+		return
+	}
+	if !a.authorized(w, r) {
+		return
+	}
+	days, err := strconv.Atoi(r.URL.Query().Get("days"))
+	if err != nil || days < 1 {
+		http.Error(w, fmt.Sprintf("invalid days: expecting a positive number of days (got %v)", r.URL.Query().Get("days")), http.StatusBadRequest)
+		return
+	}
+	before := time.Now().Unix() - int64(days)*OneDay
+	expired, err := a.store.ExpireProfileNodes(before, a.status.Get().Node)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot expire profile nodes: %v", err), http.StatusInternalServerError)
+		return
+	}
+	res := ExpiredNodes{Expired: []string{}}
+	for _, pub := range expired {
+		res.Expired = append(res.Expired, hex.EncodeToString(pub))
+	}
+	if len(expired) > 0 {
+		a.announceChanges <- spec.NodesChangedMsg{}
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
+
+func (a *WebAPI) sendProfileNodes(w http.ResponseWriter, opts string) {
+	list, err := a.store.ListProfileNodes()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot list profile nodes: %v", err), http.StatusInternalServerError)
+		return
+	}
+	current := a.status.Get().Node
+	res := make([]ProfileNode, 0, len(list))
+	for _, node := range list {
+		res = append(res, ProfileNode{
+			PubKey:  hex.EncodeToString(node.PubKey),
+			Seen:    node.Time,
+			Current: current != nil && bytes.Equal(node.PubKey, current),
+		})
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
+
+func parseNodeKey(arg string) ([]byte, error) {
+	pub, err := hex.DecodeString(arg)
+	if err != nil || len(pub) != 32 {
+		return nil, fmt.Errorf("invalid node pubkey: expecting 32 bytes hex-encoded (got %v)", arg)
+	}
+	return pub, nil
+}
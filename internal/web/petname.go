@@ -0,0 +1,195 @@
+package web
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"code.dogecoin.org/identity/internal/auth"
+	"code.dogecoin.org/identity/internal/profile"
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// Petnames are our own private names and notes for identities.
+// They are never gossiped, and only shown to admin callers.
+
+const MaxPetnameBytes = 60
+const MaxNoteBytes = 1000
+
+// Petname is our private name and note for an identity.
+type Petname struct {
+	Identity string `json:"identity"` // identity pubkey (hex)
+	Petname  string `json:"petname"`  // our name for them
+	Note     string `json:"note"`     // free-text note
+	Edited   int64  `json:"edited"`   // unix time last edited
+}
+
+// SetPetname is the request body for POST /petnames/{hex}
+type SetPetname struct {
+	Petname string `json:"petname"`
+	Note    string `json:"note"`
+}
+
+// getIdentity gets the stored profile of one identity: GET /identity/{hex}
+// (with our petname and note if the admin token is given)
+func (a *WebAPI) getIdentity(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, OPTIONS"
+	if r.Method != http.MethodGet {
+		options(w, r, opts)
+		return
+	}
+	idenPub, err := parseIdentityKey(strings.TrimPrefix(r.URL.Path, "/identity/"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	payload, _, _, err := a.store.GetIdentity(idenPub)
+	if err != nil {
+		if spec.IsNotFoundError(err) {
+			http.Error(w, "identity not found", http.StatusNotFound)
+		} else {
+			http.Error(w, fmt.Sprintf("cannot load identity: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+	pro, ok := profile.DecodeIdentity(payload)
+	if !ok {
+		http.Error(w, "cannot decode stored identity", http.StatusInternalServerError)
+		return
+	}
+	if a.blocked(idenPub, &pro) {
+		http.Error(w, "identity not found", http.StatusNotFound)
+		return
+	}
+	res := a.identityProfile(idenPub, &pro, a.identityVerified(idenPub, pro.Nodes))
+	if auth.Check(r, a.adminToken) {
+		a.addPetname(&res, idenPub)
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
+
+// petnames lists our petnames: GET /petnames
+func (a *WebAPI) petnames(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, OPTIONS"
+	if r.Method != http.MethodGet {
+		options(w, r, opts)
+		return
+	}
+	if !a.authorized(w, r) {
+		return
+	}
+	a.sendPetnames(w, opts)
+}
+
+// petname sets or removes our petname and note for an identity:
+// POST /petnames/{hex} {"petname":"...","note":"..."}, DELETE /petnames/{hex}
+func (a *WebAPI) petname(w http.ResponseWriter, r *http.Request) {
+	opts := "POST, DELETE, OPTIONS"
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		options(w, r, opts)
+		return
+	}
+	if !a.authorized(w, r) {
+		return
+	}
+	idenPub, err := parseIdentityKey(strings.TrimPrefix(r.URL.Path, "/petnames/"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		err = a.store.RemovePetname(idenPub)
+		if err != nil {
+			if spec.IsNotFoundError(err) {
+				http.Error(w, "petname not found", http.StatusNotFound)
+			} else {
+				http.Error(w, fmt.Sprintf("cannot remove petname: %v", err), http.StatusInternalServerError)
+			}
+			return
+		}
+		a.sendPetnames(w, opts)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("bad request: %v", err), http.StatusBadRequest)
+		return
+	}
+	var req SetPetname
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error decoding JSON: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	petname, err := profile.NormalizeText("petname", strings.TrimSpace(req.Petname), MaxPetnameBytes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	note, err := profile.NormalizeNote("note", strings.TrimSpace(req.Note), MaxNoteBytes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if petname == "" && note == "" {
+		http.Error(w, "petname and note are both empty: use DELETE to remove", http.StatusBadRequest)
+		return
+	}
+	err = a.store.SetPetname(idenPub, petname, note)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot set petname: %v", err), http.StatusInternalServerError)
+		return
+	}
+	a.sendPetnames(w, opts)
+}
+
+func (a *WebAPI) sendPetnames(w http.ResponseWriter, opts string) {
+	list, err := a.store.ListPetnames()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot list petnames: %v", err), http.StatusInternalServerError)
+		return
+	}
+	res := make([]Petname, 0, len(list))
+	for _, p := range list {
+		res = append(res, Petname{Identity: hex.EncodeToString(p.PubKey), Petname: p.Petname, Note: p.Note, Edited: p.Time})
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
+
+// addPetname adds our petname and note (if any) to a profile.
+func (a *WebAPI) addPetname(res *Profile, idenPub []byte) {
+	p, err := a.store.GetPetname(idenPub)
+	if err != nil {
+		if !spec.IsNotFoundError(err) {
+			log.Printf("[web] cannot load petname: %v", err)
+		}
+		return
+	}
+	res.Petname = p.Petname
+	res.Note = p.Note
+}
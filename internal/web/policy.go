@@ -0,0 +1,173 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"code.dogecoin.org/gossip/iden"
+	"code.dogecoin.org/identity/internal/policy"
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// PolicyRule blocks or allows an identity, a node or a name pattern.
+type PolicyRule struct {
+	Kind   string `json:"kind"`             // "identity", "node" or "name"
+	Value  string `json:"value"`            // pubkey (hex) or name pattern ('*' matches anything)
+	Allow  bool   `json:"allow"`            // allow instead of block (identity and node only)
+	Reason string `json:"reason"`           // why
+	Source string `json:"source,omitempty"` // blocklist it was imported from (none if added locally)
+	Added  int64  `json:"added"`            // unix time added
+}
+
+// blocked is true if the local policy blocks an identity;
+// blocked identities are treated as not stored.
+func (a *WebAPI) blocked(idenPub []byte, pro *iden.IdentityMsg) bool {
+	return a.policy.Blocked(idenPub, pro.Name, pro.Nodes) != ""
+}
+
+// policyRules lists, adds or removes rules:
+// GET /policy, POST /policy {"kind":"identity","value":hex,"allow":false,"reason":"..."},
+// DELETE /policy?kind=name&value=pattern
+func (a *WebAPI) policyRules(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, POST, DELETE, OPTIONS"
+	switch r.Method {
+	case http.MethodGet:
+		// the rules are private, like petnames
+		if !a.authorized(w, r) {
+			return
+		}
+		a.sendPolicy(w, opts)
+	case http.MethodPost:
+		if !a.authorized(w, r) {
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("bad request: %v", err), http.StatusBadRequest)
+			return
+		}
+		var req PolicyRule
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error decoding JSON: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		rule, err := policy.CheckRule(spec.PolicyRule{Kind: req.Kind, Value: req.Value, Allow: req.Allow, Reason: req.Reason})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !a.setPolicyRules(w, []spec.PolicyRule{rule}) {
+			return
+		}
+		a.sendPolicy(w, opts)
+	case http.MethodDelete:
+		if !a.authorized(w, r) {
+			return
+		}
+		query := r.URL.Query()
+		rule, err := policy.CheckRule(spec.PolicyRule{Kind: query.Get("kind"), Value: query.Get("value")})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = a.store.RemovePolicyRule(rule.Kind, rule.Value)
+		if err != nil {
+			if spec.IsNotFoundError(err) {
+				http.Error(w, "rule not found", http.StatusNotFound)
+			} else {
+				http.Error(w, fmt.Sprintf("cannot remove rule: %v", err), http.StatusInternalServerError)
+			}
+			return
+		}
+		if !a.reloadPolicy(w) {
+			return
+		}
+		a.sendPolicy(w, opts)
+	default:
+		options(w, r, opts)
+	}
+}
+
+// importBlocklist replaces the rules imported from a shared blocklist file
+// (local rules are kept): POST /policy/import?source=name (body is the
+// file, see policy.ParseBlocklist)
+func (a *WebAPI) importBlocklist(w http.ResponseWriter, r *http.Request) {
+	opts := "POST, OPTIONS"
+	if r.Method != http.MethodPost {
+		options(w, r, opts)
+		return
+	}
+	if !a.authorized(w, r) {
+		return
+	}
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		source = policy.SourceImport
+	}
+	source, err := policy.CheckSource(source)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rules, err := policy.ParseBlocklist(http.MaxBytesReader(w, r.Body, a.settings.Limits().MaxImport), source)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = a.store.ImportPolicyRules(source, rules)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot store rules: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !a.reloadPolicy(w) {
+		return
+	}
+	a.sendPolicy(w, opts)
+}
+
+func (a *WebAPI) setPolicyRules(w http.ResponseWriter, rules []spec.PolicyRule) bool {
+	err := a.store.SetPolicyRules(rules)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot store rules: %v", err), http.StatusInternalServerError)
+		return false
+	}
+	return a.reloadPolicy(w)
+}
+
+// reloadPolicy updates the shared policy after changing the rules.
+func (a *WebAPI) reloadPolicy(w http.ResponseWriter) bool {
+	rules, err := a.store.ListPolicyRules()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot load rules: %v", err), http.StatusInternalServerError)
+		return false
+	}
+	a.policy.Load(rules)
+	return true
+}
+
+func (a *WebAPI) sendPolicy(w http.ResponseWriter, opts string) {
+	list, err := a.store.ListPolicyRules()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot list rules: %v", err), http.StatusInternalServerError)
+		return
+	}
+	res := make([]PolicyRule, 0, len(list))
+	for _, r := range list {
+		res = append(res, PolicyRule{Kind: r.Kind, Value: r.Value, Allow: r.Allow, Reason: r.Reason, Source: r.Source, Added: r.Time})
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
@@ -0,0 +1,103 @@
+package web
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"code.dogecoin.org/gossip/dnet"
+	"code.dogecoin.org/gossip/iden"
+	"code.dogecoin.org/identity/internal/announce"
+	"code.dogecoin.org/identity/internal/profile"
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// ProfilePreview shows the announcement a profile would produce (not stored or sent)
+type ProfilePreview struct {
+	Profile     Profile  `json:"profile"`     // decoded from the encoded payload (what peers will see)
+	Time        int64    `json:"time"`        // unix time used for signing
+	Size        int      `json:"size"`        // encoded payload size (bytes)
+	Max         int      `json:"max"`         // largest possible payload (bytes)
+	Adjustments []string `json:"adjustments"` // changes made to the submitted fields
+	Payload     string   `json:"payload"`     // encoded IdentityMsg (hex)
+	Signature   string   `json:"signature"`   // signature by the identity key (hex)
+	Message     string   `json:"message"`     // complete signed message, as it would be gossiped (hex)
+}
+
+// previewProfile encodes and signs a validated profile without storing
+// or sending it: POST /profile?preview=1
+func (a *WebAPI) previewProfile(w http.ResponseWriter, to NewIdent, pro spec.Profile, opts string) {
+	nodes, err := a.store.GetProfileNodes()
+	if err != nil && !spec.IsNotFoundError(err) {
+		http.Error(w, fmt.Sprintf("cannot load profile nodes: %v", err), http.StatusInternalServerError)
+		return
+	}
+	msg := announce.IdentityFromProfile(pro, nodes)
+	msg.Time = dnet.DogeNow()
+	payload, err := announce.EncodeIdentity(&msg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	view := announce.SignIdentity(a.idenKey, payload)
+	decoded, ok := profile.DecodeIdentity(payload)
+	if !ok {
+		http.Error(w, "cannot decode the encoded identity", http.StatusInternalServerError)
+		return
+	}
+	res := ProfilePreview{
+		Profile:     profileFromIdentity(&decoded, a.identityVerified(a.idenKey.Pub[:], decoded.Nodes)),
+		Time:        decoded.Time.Local().Unix(),
+		Size:        len(payload),
+		Max:         iden.IdenMsgAlloc,
+		Adjustments: profileAdjustments(to, pro, len(nodes)),
+		Payload:     hex.EncodeToString(payload),
+		Signature:   hex.EncodeToString(view.Signature()[:]),
+		Message:     hex.EncodeToString(append(view.Header(), payload...)),
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
+
+// profileAdjustments describes how ValidateProfile changed the submitted fields.
+func profileAdjustments(to NewIdent, pro spec.Profile, numNodes int) []string {
+	res := []string{}
+	lon := to.Lon
+	if to.Long != 0 && to.Lon == 0 {
+		lon = to.Long
+		res = append(res, "used deprecated 'long' as 'lon'")
+	}
+	if q := float64(pro.Lat) / 10.0; q != to.Lat {
+		res = append(res, fmt.Sprintf("lat %v rounded to %.1f", to.Lat, q))
+	}
+	if q := float64(pro.Lon) / 10.0; q != lon {
+		res = append(res, fmt.Sprintf("lon %v rounded to %.1f", lon, q))
+	}
+	for _, f := range []struct{ field, from, to string }{{"name", to.Name, pro.Name}, {"bio", to.Bio, pro.Bio}, {"city", to.City, pro.City}} {
+		if f.from != f.to {
+			res = append(res, fmt.Sprintf("%v normalised to Unicode NFC", f.field))
+		}
+	}
+	if pro.Country != to.Country {
+		res = append(res, fmt.Sprintf("country %q changed to %q", to.Country, pro.Country))
+	}
+	if strings.TrimSpace(to.Icon) != "" && len(pro.Icon) == 0 {
+		res = append(res, "icon dropped")
+	}
+	if numNodes == 0 {
+		res = append(res, "no node pubkeys yet: the announcement will not be gossiped until dogenet connects")
+	}
+	return res
+}
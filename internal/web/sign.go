@@ -0,0 +1,140 @@
+package web
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"code.dogecoin.org/identity/internal/profile"
+	"code.dogecoin.org/identity/internal/sign"
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// SignRequest is the request body for POST /sign
+type SignRequest struct {
+	Domain  string `json:"domain"`  // app or site asking for the signature
+	Message string `json:"message"` // e.g. a login challenge
+}
+
+// Signed is a message signed by an identity; also the request body for POST /verify
+type Signed struct {
+	Identity  string `json:"identity"`  // identity pubkey (hex)
+	Domain    string `json:"domain"`    // app or site asking for the signature
+	Message   string `json:"message"`   // signed message
+	Signature string `json:"signature"` // 64-byte Schnorr signature (hex)
+}
+
+// Verified is the response to POST /verify
+type Verified struct {
+	Valid    bool   `json:"valid"`          // signature is valid for the identity
+	Identity string `json:"identity"`       // identity pubkey (hex)
+	Known    bool   `json:"known"`          // identity is stored (and not blocked)
+	Name     string `json:"name,omitempty"` // its announced name, if known
+}
+
+// postSign signs a domain-separated message with our identity key:
+// POST /sign {"domain":"example.org","message":"..."}
+func (a *WebAPI) postSign(w http.ResponseWriter, r *http.Request) {
+	opts := "POST, OPTIONS"
+	if r.Method != http.MethodPost {
+		options(w, r, opts)
+		return
+	}
+	if !a.authorized(w, r) {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("bad request: %v", err), http.StatusBadRequest)
+		return
+	}
+	var req SignRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error decoding JSON: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	sig, err := sign.Sign(a.idenKey, req.Domain, req.Message)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("[web] signed a message for domain: %v", req.Domain)
+	res := Signed{
+		Identity:  hex.EncodeToString(a.idenKey.Pub[:]),
+		Domain:    req.Domain,
+		Message:   req.Message,
+		Signature: hex.EncodeToString(sig),
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
+
+// postVerify checks a signed message against any identity pubkey:
+// POST /verify {"identity":hex,"domain":"...","message":"...","signature":hex}
+func (a *WebAPI) postVerify(w http.ResponseWriter, r *http.Request) {
+	opts := "POST, OPTIONS"
+	if r.Method != http.MethodPost {
+		options(w, r, opts)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("bad request: %v", err), http.StatusBadRequest)
+		return
+	}
+	var req Signed
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error decoding JSON: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	idenPub, err := parseIdentityKey(req.Identity)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sig, err := hex.DecodeString(req.Signature)
+	if err != nil {
+		http.Error(w, "invalid signature: expecting 64 bytes hex-encoded", http.StatusBadRequest)
+		return
+	}
+	valid, err := sign.Verify(idenPub, req.Domain, req.Message, sig)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res := Verified{Valid: valid, Identity: hex.EncodeToString(idenPub)}
+	payload, _, _, err := a.store.GetIdentity(idenPub)
+	if err == nil {
+		if pro, ok := profile.DecodeIdentity(payload); ok && !a.blocked(idenPub, &pro) {
+			res.Known = true
+			res.Name = pro.Name
+		}
+	} else if !spec.IsNotFoundError(err) {
+		log.Printf("[web] cannot load identity: %v", err)
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
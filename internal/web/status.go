@@ -0,0 +1,77 @@
+package web
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"code.dogecoin.org/identity/internal/profile"
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// Status describes the local identity and its announcement.
+type Status struct {
+	Identity     string   `json:"identity"`     // our identity pubkey (hex)
+	Announcement *Profile `json:"announcement"` // current signed announcement (null if none)
+	Signed       int64    `json:"signed"`       // unix time the announcement was signed (0 if none)
+	Expires      int64    `json:"expires"`      // unix time the announcement must be re-signed (0 if none)
+	Nodes        []string `json:"nodes"`        // node pubkeys to include in the announcement (hex)
+	Pending      bool     `json:"pending"`      // waiting to (re-)sign the announcement
+	Reason       string   `json:"reason"`       // why it is pending
+	Gossiped     int64    `json:"gossiped"`     // unix time it was last sent to dogenet (0 if never)
+	Connected    bool     `json:"connected"`    // connected to dogenet
+	Node         string   `json:"node"`         // pubkey of our dogenet node (hex, empty if unknown)
+}
+
+// getStatus describes what this node is currently announcing.
+func (a *WebAPI) getStatus(w http.ResponseWriter, r *http.Request) {
+	opts := "GET, OPTIONS"
+	if r.Method != http.MethodGet {
+		options(w, r, opts)
+		return
+	}
+	st := a.status.Get()
+	res := Status{
+		Identity:  hex.EncodeToString(st.Identity),
+		Nodes:     []string{},
+		Pending:   st.Pending != "",
+		Reason:    st.Pending,
+		Gossiped:  st.LastGossiped,
+		Connected: st.Connected,
+		Node:      hex.EncodeToString(st.Node),
+	}
+	payload, _, expires, err := a.store.GetAnnounce()
+	if err != nil && !spec.IsNotFoundError(err) {
+		http.Error(w, fmt.Sprintf("cannot load announcement: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err == nil && len(payload) > 0 {
+		if msg, ok := profile.DecodeIdentity(payload); ok {
+			pro := a.identityProfile(st.Identity, &msg, a.identityVerified(st.Identity, msg.Nodes))
+			res.Announcement = &pro
+			res.Signed = msg.Time.Local().Unix()
+			res.Expires = expires
+		}
+	}
+	nodes, err := a.store.GetProfileNodes()
+	if err != nil && !spec.IsNotFoundError(err) {
+		http.Error(w, fmt.Sprintf("cannot load profile nodes: %v", err), http.StatusInternalServerError)
+		return
+	}
+	for _, node := range nodes {
+		res.Nodes = append(res.Nodes, hex.EncodeToString(node))
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Allow", opts)
+	w.Write(bytes)
+}
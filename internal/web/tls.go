@@ -0,0 +1,115 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"code.dogecoin.org/identity/internal/spec"
+)
+
+// Self-signed certificate for serving the web API over TLS,
+// generated into the storage directory on first use.
+
+const CertFileName = "web.crt"
+const KeyFileName = "web.key"
+const CertValidity = 10 * 365 * 24 * time.Hour
+
+// LoadOrCreateCert loads the certificate and key, or generates a
+// self-signed pair valid for localhost and the IPs in binds. If only one
+// of the files exists it is replaced, since it's no use without the other.
+func LoadOrCreateCert(certFile string, keyFile string, binds []spec.BindTo) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err == nil {
+		return cert, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return tls.Certificate{}, err
+	}
+	for _, fileName := range []string{certFile, keyFile} {
+		err = os.Remove(fileName)
+		if err == nil {
+			log.Printf("[web] replacing %v: its certificate or key is missing", fileName)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return tls.Certificate{}, err
+		}
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	tmpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "localhost", Organization: []string{"Dogecoin Identity"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(CertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	for _, bind := range binds {
+		if bind.Network != "tcp" {
+			continue
+		}
+		host, _, err := net.SplitHostPort(bind.Address)
+		if err != nil {
+			continue
+		}
+		ip := net.ParseIP(host)
+		if ip != nil && !ip.IsUnspecified() && !ip.IsLoopback() {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	// write the key first (private), then the certificate
+	err = writeNewFile(keyFile, keyPem, 0600)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	err = writeNewFile(certFile, certPem, 0644)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPem, keyPem)
+}
+
+// writeNewFile creates a file that must not already exist.
+func writeNewFile(fileName string, data []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Close()
+	} else {
+		file.Close()
+	}
+	return err
+}
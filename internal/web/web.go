@@ -3,6 +3,7 @@ package web
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -10,14 +11,23 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"code.dogecoin.org/gossip/dnet"
 	"code.dogecoin.org/gossip/iden"
 	"code.dogecoin.org/governor"
+	"code.dogecoin.org/identity/internal/auth"
+	"code.dogecoin.org/identity/internal/config"
+	"code.dogecoin.org/identity/internal/domains"
+	"code.dogecoin.org/identity/internal/policy"
+	"code.dogecoin.org/identity/internal/profile"
 	"code.dogecoin.org/identity/internal/spec"
 
 	"github.com/rs/cors"
@@ -25,23 +35,75 @@ import (
 
 const DogeIconSize = dnet.DogeIconSize + 1 // +1 for style byte (XXX fix in gossip pkg)
 
-func New(bind dnet.Address, webdir string, announceChanges chan any, store spec.Store) governor.Service {
+func New(binds []spec.BindTo, tlsConfig *tls.Config, webdir string, announceChanges chan any, outgoing chan dnet.RawMessage, store spec.Store, status *spec.Status, idenKey dnet.KeyPair, adminToken string, settings *config.Settings, policy *policy.Policy, verifier *domains.Verifier) governor.Service {
 	mux := http.NewServeMux()
 	a := &WebAPI{
-		srv: http.Server{
-			Addr:    bind.String(),
-			Handler: cors.AllowAll().Handler(mux),
-		},
+		binds:           binds,
+		tlsConfig:       tlsConfig,
 		announceChanges: announceChanges,
+		outgoing:        outgoing,
 		_store:          store,
+		status:          status,
+		idenKey:         idenKey,
+		adminToken:      adminToken,
+		settings:        settings,
+		policy:          policy,
+		verifier:        verifier,
+		refreshing:      make(map[string]bool),
 	}
-
-	mux.HandleFunc("/profile", a.postIdent)
-	mux.HandleFunc("/locations", a.getLocations)
-	mux.HandleFunc("/chits", a.getChits)
+	// cross-origin requests are only allowed from the configured origins
+	a.srv.Handler = cors.New(cors.Options{
+		AllowOriginFunc: settings.AllowOrigin,
+		AllowedMethods:  []string{http.MethodGet, http.MethodPost, http.MethodDelete},
+		AllowedHeaders:  []string{"Authorization", "Content-Type", "Last-Event-ID"},
+	}).Handler(mux)
+
+	// record request latency per route (the mux pattern)
+	handle := func(pattern string, handler http.Handler) {
+		mux.Handle(pattern, timed(pattern, handler))
+	}
+	handle("/profile", http.HandlerFunc(a.postIdent))
+	handle("/profile/icon.png", http.HandlerFunc(a.getProfileIcon))
+	handle("/profile/icon", http.HandlerFunc(a.postProfileIcon))
+	handle("/profile/vcard", http.HandlerFunc(a.postProfileVCard))
+	handle("/profile/nodes", http.HandlerFunc(a.profileNodes))
+	handle("/profile/nodes/", http.HandlerFunc(a.profileNode))
+	handle("/locations", http.HandlerFunc(a.getLocations))
+	handle("/chits", http.HandlerFunc(a.getChits))
+	// streams last as long as the client stays connected, so aren't timed
+	mux.HandleFunc("/events", a.getEvents)
+	mux.HandleFunc("/ws", a.getWebSocket)
+	handle("/icon/", http.HandlerFunc(a.getIcon))
+	handle("/geojson", http.HandlerFunc(a.getGeoJSON))
+	handle("/nearby", http.HandlerFunc(a.getNearby))
+	handle("/contacts", http.HandlerFunc(a.contacts))
+	handle("/contacts/", http.HandlerFunc(a.contact))
+	handle("/identity/", http.HandlerFunc(a.getIdentity))
+	handle("/petnames", http.HandlerFunc(a.petnames))
+	handle("/petnames/", http.HandlerFunc(a.petname))
+	handle("/policy", http.HandlerFunc(a.policyRules))
+	handle("/policy/import", http.HandlerFunc(a.importBlocklist))
+	handle("/export/vcard", http.HandlerFunc(a.exportVCard))
+	handle("/export/vcard/", http.HandlerFunc(a.exportVCard))
+	handle("/export/jsonld", http.HandlerFunc(a.exportJSONLD))
+	handle("/export/jsonld/", http.HandlerFunc(a.exportJSONLD))
+	handle("/1.0/identifiers/", http.HandlerFunc(a.resolveDID))
+	handle("/domains", http.HandlerFunc(a.domainClaims))
+	handle("/domains/", http.HandlerFunc(a.domainClaim))
+	handle(domains.WellKnownPath, http.HandlerFunc(a.getDogeID))
+	handle("/sign", http.HandlerFunc(a.postSign))
+	handle("/verify", http.HandlerFunc(a.postVerify))
+	handle("/endorse", http.HandlerFunc(a.postEndorse))
+	handle("/endorsements/", http.HandlerFunc(a.getEndorsements))
+	handle("/trust", http.HandlerFunc(a.getTrust))
+	handle("/trust/", http.HandlerFunc(a.getTrust))
+	handle("/metrics", http.HandlerFunc(a.getMetrics))
+	handle("/healthz", http.HandlerFunc(a.getHealthz))
+	handle("/readyz", http.HandlerFunc(a.getReadyz))
+	handle("/status", http.HandlerFunc(a.getStatus))
 
 	fs := http.FileServer(http.Dir(webdir))
-	mux.Handle("/", fs)
+	handle("/", fs)
 
 	return a
 }
@@ -49,9 +111,31 @@ func New(bind dnet.Address, webdir string, announceChanges chan any, store spec.
 type WebAPI struct {
 	governor.ServiceCtx
 	srv             http.Server
+	binds           []spec.BindTo // tcp ip:port or unix socket paths
+	tlsConfig       *tls.Config   // TLS on tcp binds (nil for plain HTTP)
 	announceChanges chan any
+	outgoing        chan dnet.RawMessage // our own messages for the handler to gossip
 	_store          spec.Store
 	store           spec.StoreCtx
+	status          *spec.Status     // reported by the handler and announce services
+	idenKey         dnet.KeyPair     // for signing previews (see /profile?preview=1)
+	adminToken      string           // required on mutating endpoints
+	settings        *config.Settings // reloadable: allowed cross-origin callers, limits
+	policy          *policy.Policy   // blocked identities are hidden from lookups
+	verifier        *domains.Verifier
+	refreshMu       sync.Mutex
+	refreshing      map[string]bool // domain claims being re-verified
+}
+
+// authorized checks for the admin token on a mutating request,
+// and replies 401 Unauthorized if it is missing.
+func (a *WebAPI) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if auth.Check(r, a.adminToken) {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="identity"`)
+	http.Error(w, "unauthorized: admin token required", http.StatusUnauthorized)
+	return false
 }
 
 func (a *WebAPI) Stop() {
@@ -66,10 +150,53 @@ func (a *WebAPI) Stop() {
 
 func (a *WebAPI) Run() {
 	a.store = a._store.WithCtx(a.Context)
-	log.Printf("HTTP server listening on: %v\n", a.srv.Addr)
-	if err := a.srv.ListenAndServe(); err != http.ErrServerClosed { // blocking call
-		log.Printf("HTTP server: %v\n", err)
+	var listeners []net.Listener
+	for _, bind := range a.binds {
+		lis, err := listen(bind, a.tlsConfig)
+		if err != nil {
+			log.Printf("HTTP server: %v\n", err)
+			for _, l := range listeners {
+				l.Close()
+			}
+			return
+		}
+		listeners = append(listeners, lis)
 	}
+	var wg sync.WaitGroup
+	for i, lis := range listeners {
+		scheme := "http"
+		if a.binds[i].Network == "tcp" && a.tlsConfig != nil {
+			scheme = "https"
+		}
+		log.Printf("HTTP server listening on: %v (%v)\n", a.binds[i].Address, scheme)
+		wg.Add(1)
+		go func(lis net.Listener) {
+			defer wg.Done()
+			if err := a.srv.Serve(lis); err != http.ErrServerClosed { // blocking call
+				log.Printf("HTTP server: %v\n", err)
+			}
+		}(lis)
+	}
+	wg.Wait()
+}
+
+// listen on a tcp ip:port (with TLS if configured) or a unix socket.
+func listen(bind spec.BindTo, tlsConfig *tls.Config) (net.Listener, error) {
+	if bind.Network == "unix" {
+		// remove a stale socket left behind by a previous run
+		if ent, err := os.Lstat(bind.Address); err == nil && ent.Mode()&os.ModeSocket != 0 {
+			os.Remove(bind.Address)
+		}
+		return net.Listen("unix", bind.Address)
+	}
+	lis, err := net.Listen(bind.Network, bind.Address)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		lis = tls.NewListener(lis, tlsConfig)
+	}
+	return lis, nil
 }
 
 type NewIdent struct {
@@ -90,9 +217,59 @@ const (
 	maxLon = 180.0
 )
 
+// ValidateProfile checks the profile fields and quantizes the location.
+func ValidateProfile(to NewIdent) (spec.Profile, error) {
+	name, err := profile.NormalizeText("name", to.Name, profile.MaxNameBytes)
+	if err != nil {
+		return spec.Profile{}, err
+	}
+	bio, err := profile.NormalizeText("bio", to.Bio, profile.MaxBioBytes)
+	if err != nil {
+		return spec.Profile{}, err
+	}
+	if to.Lat < minLat || to.Lat > maxLat {
+		return spec.Profile{}, fmt.Errorf("invalid latitude: out of range [%v, %v] (got %v)", minLat, maxLat, to.Lat)
+	}
+	lat := int(math.Round(to.Lat * 10)) // quantize to nearest 0.1 degree
+	if to.Long != 0 && to.Lon == 0 {
+		to.Lon = to.Long // migration: `Long` is deprecated.
+	}
+	if to.Lon < minLon || to.Lon > maxLon {
+		return spec.Profile{}, fmt.Errorf("invalid longitude: out of range [%v, %v] (got %v)", minLon, maxLon, to.Lon)
+	}
+	long := int(math.Round(to.Lon * 10))   // quantize to nearest 0.1 degree
+	country := strings.ToUpper(to.Country) // by convention
+	if country != "" && !profile.IsCountry(country) {
+		return spec.Profile{}, fmt.Errorf("invalid country: expecting ISO 3166-1 alpha-2 code (got %q)", to.Country)
+	}
+	city, err := profile.NormalizeText("city", to.City, profile.MaxCityBytes)
+	if err != nil {
+		return spec.Profile{}, err
+	}
+	icon, err := base64.StdEncoding.DecodeString(to.Icon)
+	if err != nil {
+		return spec.Profile{}, fmt.Errorf("invalid icon: %v", err.Error())
+	}
+	if len(icon) != DogeIconSize && len(icon) != 0 {
+		return spec.Profile{}, fmt.Errorf("invalid icon: expecting %v bytes (got %v)", DogeIconSize, len(icon))
+	}
+	return spec.Profile{
+		Name:    name,
+		Bio:     bio,
+		Lat:     lat,
+		Lon:     long,
+		Country: country,
+		City:    city,
+		Icon:    icon,
+	}, nil
+}
+
 func (a *WebAPI) postIdent(w http.ResponseWriter, r *http.Request) {
 	opts := "GET, POST, OPTIONS"
 	if r.Method == http.MethodPost {
+		if !a.authorized(w, r) {
+			return
+		}
 		// request
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
@@ -106,56 +283,16 @@ func (a *WebAPI) postIdent(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// validate profile fields
-		if len(to.Name) > 30 {
-			http.Error(w, fmt.Sprintf("invalid name: more than 30 characters (got %v)", len(to.Name)), http.StatusBadRequest)
-			return
-		}
-		if len(to.Bio) > 120 {
-			http.Error(w, fmt.Sprintf("invalid bio: more than 120 characters (got %v)", len(to.Bio)), http.StatusBadRequest)
-			return
-		}
-		if to.Lat < minLat || to.Lat > maxLat {
-			http.Error(w, fmt.Sprintf("invalid latitude: out of range [%v, %v] (got %v)", minLat, maxLat, to.Lat), http.StatusBadRequest)
-			return
-		}
-		lat := int(to.Lat * 10) // quantize to nearest 0.1 degree
-		if to.Long != 0 && to.Lon == 0 {
-			to.Lon = to.Long // migration: `Long` is deprecated.
-		}
-		if to.Lon < minLon || to.Lon > maxLon {
-			http.Error(w, fmt.Sprintf("invalid longitude: out of range [%v, %v] (got %v)", minLon, maxLon, to.Lon), http.StatusBadRequest)
-			return
-		}
-		long := int(to.Lon * 10) // quantize to nearest 0.1 degree
-		if len(to.Country) != 2 && len(to.Country) != 0 {
-			http.Error(w, fmt.Sprintf("invalid country: expecting ISO 3166-1 alpha-2 code (got %v)", len(to.Country)), http.StatusBadRequest)
-			return
-		}
-		to.Country = strings.ToUpper(to.Country) // by convention
-		if len(to.City) > 30 {
-			http.Error(w, fmt.Sprintf("invalid city: more than 30 characters (got %v)", len(to.City)), http.StatusBadRequest)
-			return
-		}
-		icon, err := base64.StdEncoding.DecodeString(to.Icon)
+		pro, err := ValidateProfile(to)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("invalid icon: %v", err.Error()), http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		if len(icon) != DogeIconSize && len(icon) != 0 {
-			http.Error(w, fmt.Sprintf("invalid icon: expecting %v bytes (got %v)", DogeIconSize, len(icon)), http.StatusBadRequest)
+		if r.URL.Query().Get("preview") == "1" {
+			// dry run: encode and sign, but don't store or gossip
+			a.previewProfile(w, to, pro, opts)
 			return
 		}
-
-		pro := spec.Profile{
-			Name:    to.Name,
-			Bio:     to.Bio,
-			Lat:     lat,
-			Lon:     long,
-			Country: to.Country,
-			City:    to.City,
-			Icon:    icon,
-		}
 		err = a.store.SetProfile(pro)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("cannot store profile: %v", err), http.StatusInternalServerError)
@@ -183,10 +320,11 @@ func (a *WebAPI) postIdent(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func sendProfile(w http.ResponseWriter, pro *spec.Profile, opts string) {
+// NewIdentFromProfile converts a stored profile to its JSON form.
+func NewIdentFromProfile(pro *spec.Profile) NewIdent {
 	lat := float64(pro.Lat) / 10.0 // undo quantization
 	lon := float64(pro.Lon) / 10.0 // undo quantization
-	res := NewIdent{
+	return NewIdent{
 		Name:    pro.Name,
 		Bio:     pro.Bio,
 		Lat:     lat,
@@ -196,7 +334,10 @@ func sendProfile(w http.ResponseWriter, pro *spec.Profile, opts string) {
 		City:    pro.City,
 		Icon:    base64.StdEncoding.EncodeToString(pro.Icon),
 	}
-	bytes, err := json.Marshal(res)
+}
+
+func sendProfile(w http.ResponseWriter, pro *spec.Profile, opts string) {
+	bytes, err := json.Marshal(NewIdentFromProfile(pro))
 	if err != nil {
 		http.Error(w, fmt.Sprintf("error encoding JSON: %s", err.Error()), http.StatusInternalServerError)
 		return
@@ -214,10 +355,34 @@ type GetChit struct {
 
 // Location contains only the location information for a profile.
 type Location struct {
-	Lat     string `json:"lat"`     // WGS84 +/- 90 degrees, floating point
-	Lon     string `json:"lon"`     // WGS84 +/- 180 degrees, floating point
-	Country string `json:"country"` // [2] ISO 3166-1 alpha-2 code (optional)
-	City    string `json:"city"`    // [30] city name (optional)
+	Lat      string `json:"lat"`      // WGS84 +/- 90 degrees, floating point
+	Lon      string `json:"lon"`      // WGS84 +/- 180 degrees, floating point
+	Country  string `json:"country"`  // [2] ISO 3166-1 alpha-2 code (optional)
+	City     string `json:"city"`     // [30] city name (optional)
+	Verified bool   `json:"verified"` // node also claims this identity
+}
+
+// identityVerified returns true if any of the identity's nodes claims it.
+func (a *WebAPI) identityVerified(idenPub []byte, nodes [][]byte) bool {
+	for _, nodePub := range nodes {
+		if a.nodeClaimsIdentity(nodePub, idenPub) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeClaimsIdentity returns true if the node has announced that
+// it belongs to the identity (the other side of the ownership claim)
+func (a *WebAPI) nodeClaimsIdentity(nodePub []byte, idenPub []byte) bool {
+	claimed, _, err := a.store.GetNodeClaim(nodePub)
+	if err != nil {
+		if !spec.IsNotFoundError(err) {
+			log.Printf("[web] cannot load node claim: %v", err)
+		}
+		return false
+	}
+	return bytes.Equal(claimed, idenPub)
 }
 
 // getLocations returns location metadata for a set of identity pubkeys.
@@ -259,7 +424,11 @@ func (a *WebAPI) getLocations(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, fmt.Sprintf("identity not found '%v': %v", idenPub, err), http.StatusBadRequest)
 				return
 			}
-			pro := iden.DecodeIdentityMsg(payload)
+			pro, ok := profile.DecodeIdentity(payload)
+			if !ok || a.blocked(idenPub, &pro) {
+				// skip identities blocked by local policy.
+				continue
+			}
 			foundNode := false
 			for _, id := range pro.Nodes {
 				if bytes.Equal(id, nodePub) {
@@ -275,10 +444,11 @@ func (a *WebAPI) getLocations(w http.ResponseWriter, r *http.Request) {
 			lat := float64(pro.Lat) / 10.0  // undo quantization
 			lon := float64(pro.Long) / 10.0 // undo quantization
 			res[chit.Identity] = Location{
-				Lat:     strconv.FormatFloat(lat, 'f', 1, 64),
-				Lon:     strconv.FormatFloat(lon, 'f', 1, 64),
-				Country: pro.Country,
-				City:    pro.City,
+				Lat:      strconv.FormatFloat(lat, 'f', 1, 64),
+				Lon:      strconv.FormatFloat(lon, 'f', 1, 64),
+				Country:  pro.Country,
+				City:     pro.City,
+				Verified: a.nodeClaimsIdentity(nodePub, idenPub),
 			}
 		}
 
@@ -298,14 +468,60 @@ func (a *WebAPI) getLocations(w http.ResponseWriter, r *http.Request) {
 
 // Profile contains all gossiped profile information.
 type Profile struct {
-	Name    string   `json:"name"`    // [30] display name
-	Bio     string   `json:"bio"`     // [120] short biography
-	Lat     string   `json:"lat"`     // WGS84 +/- 90 degrees, floating point
-	Lon     string   `json:"lon"`     // WGS84 +/- 180 degrees, floating point
-	Country string   `json:"country"` // [2] ISO 3166-1 alpha-2 code
-	City    string   `json:"city"`    // [30] city name
-	Icon    string   `json:"icon"`    // [1585] compressed icon (base64-encoded)
-	Nodes   []string `json:"nodes"`   // public keys of nodes claimed by this identity (hex-encoded)
+	Name      string   `json:"name"`                // [30] display name
+	Bio       string   `json:"bio"`                 // [120] short biography
+	Lat       string   `json:"lat"`                 // WGS84 +/- 90 degrees, floating point
+	Lon       string   `json:"lon"`                 // WGS84 +/- 180 degrees, floating point
+	Country   string   `json:"country"`             // [2] ISO 3166-1 alpha-2 code
+	City      string   `json:"city"`                // [30] city name
+	Icon      string   `json:"icon"`                // [1585] compressed icon (base64-encoded)
+	Nodes     []string `json:"nodes"`               // public keys of nodes claimed by this identity (hex-encoded)
+	Verified  bool     `json:"verified"`            // node also claims this identity
+	Warning   string   `json:"warning,omitempty"`   // name looks like a pinned contact or an older identity
+	Lookalike string   `json:"lookalike,omitempty"` // pubkey of the identity it looks like (hex-encoded)
+	Domain    string   `json:"domain,omitempty"`    // name@domain verified by the domain's dogeid.json
+	Petname   string   `json:"petname,omitempty"`   // our private name for them (admin only)
+	Note      string   `json:"note,omitempty"`      // our private note (admin only)
+}
+
+// profileFromIdentity converts a decoded IdentityMsg to its JSON form.
+func profileFromIdentity(pro *iden.IdentityMsg, verified bool) Profile {
+	nodeList := make([]string, 0, len(pro.Nodes))
+	for _, id := range pro.Nodes {
+		nodeList = append(nodeList, hex.EncodeToString(id))
+	}
+	lat := float64(pro.Lat) / 10.0  // undo quantization
+	lon := float64(pro.Long) / 10.0 // undo quantization
+	return Profile{
+		Name:     pro.Name,
+		Bio:      pro.Bio,
+		Lat:      strconv.FormatFloat(lat, 'f', 1, 64),
+		Lon:      strconv.FormatFloat(lon, 'f', 1, 64),
+		Country:  pro.Country,
+		City:     pro.City,
+		Icon:     base64.StdEncoding.EncodeToString(pro.Icon),
+		Nodes:    nodeList,
+		Verified: verified,
+	}
+}
+
+// identityProfile converts a stored identity to its JSON form,
+// with a warning if its name looks like another identity's name.
+func (a *WebAPI) identityProfile(idenPub []byte, pro *iden.IdentityMsg, verified bool) Profile {
+	res := profileFromIdentity(pro, verified)
+	res.Domain = a.verifiedDomain(idenPub)
+	found, err := a.store.FindLookalike(idenPub)
+	if err != nil {
+		log.Printf("[web] cannot check name lookalikes: %v", err)
+	} else if found != nil {
+		res.Lookalike = hex.EncodeToString(found.PubKey)
+		if found.Pinned {
+			res.Warning = fmt.Sprintf("name looks like pinned contact %q", found.Name)
+		} else {
+			res.Warning = fmt.Sprintf("name looks like an older identity %q", found.Name)
+		}
+	}
+	return res
 }
 
 // getChits gets full Profiles including icons for a set of identity pubkeys.
@@ -325,6 +541,7 @@ func (a *WebAPI) getChits(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		admin := auth.Check(r, a.adminToken) // include our petnames
 		res := make(map[string]Profile, len(chits))
 		for _, chit := range chits {
 			idenPub, err := hex.DecodeString(chit.Identity)
@@ -346,7 +563,11 @@ func (a *WebAPI) getChits(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, fmt.Sprintf("identity not found '%v': %v", idenPub, err), http.StatusBadRequest)
 				return
 			}
-			pro := iden.DecodeIdentityMsg(payload)
+			pro, ok := profile.DecodeIdentity(payload)
+			if !ok || a.blocked(idenPub, &pro) {
+				// skip identities blocked by local policy.
+				continue
+			}
 			foundNode := false
 			for _, id := range pro.Nodes {
 				if bytes.Equal(id, nodePub) {
@@ -360,22 +581,11 @@ func (a *WebAPI) getChits(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
-			nodeList := make([]string, 0, len(pro.Nodes))
-			for _, id := range pro.Nodes {
-				nodeList = append(nodeList, hex.EncodeToString(id))
-			}
-			lat := float64(pro.Lat) / 10.0  // undo quantization
-			lon := float64(pro.Long) / 10.0 // undo quantization
-			res[chit.Identity] = Profile{
-				Name:    pro.Name,
-				Bio:     pro.Bio,
-				Lat:     strconv.FormatFloat(lat, 'f', 1, 64),
-				Lon:     strconv.FormatFloat(lon, 'f', 1, 64),
-				Country: pro.Country,
-				City:    pro.City,
-				Icon:    base64.StdEncoding.EncodeToString(pro.Icon),
-				Nodes:   nodeList,
+			p := a.identityProfile(idenPub, &pro, a.nodeClaimsIdentity(nodePub, idenPub))
+			if admin {
+				a.addPetname(&p, idenPub)
 			}
+			res[chit.Identity] = p
 		}
 
 		bytes, err := json.Marshal(res)
@@ -0,0 +1,314 @@
+package web
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"code.dogecoin.org/identity/internal/auth"
+	"code.dogecoin.org/identity/internal/profile"
+	"code.dogecoin.org/identity/internal/spec"
+	"github.com/gorilla/websocket"
+)
+
+const WSMaxMessageSize = 64 * 1024 // plenty for a base64 icon
+const WSPingInterval = 30 * time.Second
+const WSReadTimeout = 2 * WSPingInterval
+const WSWriteTimeout = 10 * time.Second
+
+// WebSocket message types
+const (
+	WSSubscribe   = "subscribe"   // request: receive updates for Identities
+	WSUnsubscribe = "unsubscribe" // request: stop receiving updates for Identities
+	WSLookup      = "lookup"      // request: get the current Identity profile
+	WSGetProfile  = "get_profile" // request: get the local profile
+	WSSetProfile  = "set_profile" // request: store, sign and announce a new local profile
+	WSSubscribed  = "subscribed"  // response: current subscriptions
+	WSIdentity    = "identity"    // response/update: identity profile (or Kind "expired")
+	WSProfile     = "profile"     // response: local profile
+	WSError       = "error"       // response: request failed
+)
+
+// WSRequest is a JSON message from the client.
+type WSRequest struct {
+	Type       string    `json:"type"`                 // WSSubscribe, WSLookup, ...
+	ID         int64     `json:"id,omitempty"`         // request id (echoed in the response)
+	Identity   string    `json:"identity,omitempty"`   // identity pubkey hex (lookup)
+	Identities []string  `json:"identities,omitempty"` // identity pubkeys hex (subscribe, unsubscribe)
+	Profile    *NewIdent `json:"profile,omitempty"`    // new local profile (set_profile)
+}
+
+// WSResponse is a JSON message to the client.
+type WSResponse struct {
+	Type       string    `json:"type"`                 // WSIdentity, WSProfile, ...
+	ID         int64     `json:"id,omitempty"`         // request id (zero for updates)
+	Identity   string    `json:"identity,omitempty"`   // identity pubkey hex
+	Identities []string  `json:"identities,omitempty"` // subscribed identity pubkeys hex
+	Kind       string    `json:"kind,omitempty"`       // change kind (updates only)
+	Chit       *Profile  `json:"chit,omitempty"`       // identity profile (WSIdentity)
+	Profile    *NewIdent `json:"profile,omitempty"`    // local profile (WSProfile)
+	Error      string    `json:"error,omitempty"`      // reason (WSError)
+}
+
+// wsConn is one WebSocket client and its subscriptions.
+type wsConn struct {
+	a      *WebAPI
+	conn   *websocket.Conn
+	wmu    sync.Mutex // one writer at a time
+	mu     sync.Mutex // protects subs
+	subs   map[string]bool
+	closed chan struct{}
+	admin  bool // connected with the admin token
+}
+
+// getWebSocket upgrades to a WebSocket speaking the JSON protocol above.
+func (a *WebAPI) getWebSocket(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		options(w, r, "GET, OPTIONS")
+		return
+	}
+	last, err := a.store.LatestChange()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot load changes: %v", err), http.StatusInternalServerError)
+		return
+	}
+	upgrader := websocket.Upgrader{CheckOrigin: a.checkWebSocketOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade has already replied
+	}
+	c := &wsConn{a: a, conn: conn, subs: make(map[string]bool), closed: make(chan struct{})}
+	c.admin = auth.CheckWebSocket(r, a.adminToken)
+	go c.sendUpdates(last)
+	c.readRequests()
+}
+
+// checkWebSocketOrigin applies the CORS allow-list to WebSocket connections
+// (browsers don't enforce CORS on WebSockets); same-origin is always allowed.
+func (a *WebAPI) checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true // not a browser
+	}
+	u, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return a.settings.AllowOrigin(origin)
+}
+
+func (c *wsConn) send(res WSResponse) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(WSWriteTimeout))
+	return c.conn.WriteJSON(res)
+}
+
+func (c *wsConn) sendError(id int64, err error) error {
+	return c.send(WSResponse{Type: WSError, ID: id, Error: err.Error()})
+}
+
+func (c *wsConn) readRequests() {
+	defer func() {
+		close(c.closed)
+		c.conn.Close()
+	}()
+	c.conn.SetReadLimit(WSMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(WSReadTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(WSReadTimeout))
+	})
+	for {
+		var req WSRequest
+		err := c.conn.ReadJSON(&req)
+		if err != nil {
+			if _, isClose := err.(*websocket.CloseError); !isClose {
+				log.Printf("[web] websocket: %v", err)
+			}
+			return
+		}
+		err = c.handle(req)
+		if err != nil {
+			return // cannot reply
+		}
+	}
+}
+
+func (c *wsConn) handle(req WSRequest) error {
+	switch req.Type {
+	case WSSubscribe:
+		ids, err := normalizeIdentities(req.Identities)
+		if err != nil {
+			return c.sendError(req.ID, err)
+		}
+		c.mu.Lock()
+		for _, id := range ids {
+			c.subs[id] = true
+		}
+		c.mu.Unlock()
+		err = c.send(WSResponse{Type: WSSubscribed, ID: req.ID, Identities: c.subscriptions()})
+		if err != nil {
+			return err
+		}
+		// send the current profiles so the client starts in sync
+		for _, id := range ids {
+			err = c.sendIdentity(req.ID, id, "")
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case WSUnsubscribe:
+		ids, err := normalizeIdentities(req.Identities)
+		if err != nil {
+			return c.sendError(req.ID, err)
+		}
+		c.mu.Lock()
+		for _, id := range ids {
+			delete(c.subs, id)
+		}
+		c.mu.Unlock()
+		return c.send(WSResponse{Type: WSSubscribed, ID: req.ID, Identities: c.subscriptions()})
+	case WSLookup:
+		ids, err := normalizeIdentities([]string{req.Identity})
+		if err != nil {
+			return c.sendError(req.ID, err)
+		}
+		return c.sendIdentity(req.ID, ids[0], "")
+	case WSGetProfile:
+		pro, err := c.a.store.GetProfile()
+		if err != nil && !spec.IsNotFoundError(err) {
+			return c.sendError(req.ID, fmt.Errorf("cannot load profile: %v", err))
+		}
+		res := NewIdentFromProfile(&pro)
+		return c.send(WSResponse{Type: WSProfile, ID: req.ID, Profile: &res})
+	case WSSetProfile:
+		if !c.admin {
+			return c.sendError(req.ID, fmt.Errorf("unauthorized: admin token required"))
+		}
+		if req.Profile == nil {
+			return c.sendError(req.ID, fmt.Errorf("missing profile"))
+		}
+		pro, err := ValidateProfile(*req.Profile)
+		if err != nil {
+			return c.sendError(req.ID, err)
+		}
+		err = c.a.store.SetProfile(pro)
+		if err != nil {
+			return c.sendError(req.ID, fmt.Errorf("cannot store profile: %v", err))
+		}
+		// sign and announce the new profile
+		c.a.announceChanges <- pro
+		res := NewIdentFromProfile(&pro)
+		return c.send(WSResponse{Type: WSProfile, ID: req.ID, Profile: &res})
+	default:
+		return c.sendError(req.ID, fmt.Errorf("unknown message type: %v", req.Type))
+	}
+}
+
+// normalizeIdentities validates hex pubkeys and converts them to lower-case.
+func normalizeIdentities(ids []string) ([]string, error) {
+	res := make([]string, 0, len(ids))
+	for _, id := range ids {
+		pub, err := hex.DecodeString(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity pubkey '%v': %v", id, err)
+		}
+		res = append(res, hex.EncodeToString(pub))
+	}
+	return res, nil
+}
+
+func (c *wsConn) subscriptions() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ids := make([]string, 0, len(c.subs))
+	for id := range c.subs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (c *wsConn) isSubscribed(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subs[id]
+}
+
+// sendIdentity sends the stored profile; for updates (non-empty kind)
+// an identity that is no longer stored is sent as Kind "expired".
+func (c *wsConn) sendIdentity(reqID int64, id string, kind string) error {
+	idenPub, _ := hex.DecodeString(id) // already validated
+	payload, _, _, err := c.a.store.GetIdentity(idenPub)
+	if err != nil {
+		if spec.IsNotFoundError(err) {
+			if kind == "" {
+				return c.sendError(reqID, fmt.Errorf("identity not found '%v'", id))
+			}
+			return c.send(WSResponse{Type: WSIdentity, ID: reqID, Identity: id, Kind: spec.ChangeExpired})
+		}
+		return c.sendError(reqID, fmt.Errorf("cannot load identity '%v': %v", id, err))
+	}
+	pro, ok := profile.DecodeIdentity(payload)
+	if !ok {
+		return c.sendError(reqID, fmt.Errorf("invalid identity '%v'", id))
+	}
+	if c.a.blocked(idenPub, &pro) {
+		if kind == "" {
+			return c.sendError(reqID, fmt.Errorf("identity not found '%v'", id))
+		}
+		return nil // don't announce changes to blocked identities
+	}
+	chit := c.a.identityProfile(idenPub, &pro, c.a.identityVerified(idenPub, pro.Nodes))
+	return c.send(WSResponse{Type: WSIdentity, ID: reqID, Identity: id, Kind: kind, Chit: &chit})
+}
+
+// goroutine
+func (c *wsConn) sendUpdates(last int64) {
+	ping := time.NewTicker(WSPingInterval)
+	defer ping.Stop()
+	for {
+		// must get the channel before querying, to avoid missing a change
+		wake := c.a._store.Changed()
+		changes, err := c.a.store.GetChanges(last, EventsBatchSize)
+		if err != nil {
+			log.Printf("[web] cannot load changes: %v", err)
+			c.conn.Close()
+			return
+		}
+		for _, ch := range changes {
+			last = ch.ID
+			id := hex.EncodeToString(ch.PubKey)
+			if c.isSubscribed(id) {
+				if err := c.sendIdentity(0, id, ch.Kind); err != nil {
+					c.conn.Close()
+					return
+				}
+			}
+		}
+		if len(changes) == EventsBatchSize {
+			continue // more to check
+		}
+		select {
+		case <-wake:
+		case <-ping.C:
+			c.wmu.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(WSWriteTimeout))
+			c.wmu.Unlock()
+			if err != nil {
+				c.conn.Close()
+				return
+			}
+		case <-c.closed:
+			return
+		case <-c.a.Context.Done():
+			c.conn.Close()
+			return
+		}
+	}
+}
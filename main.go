@@ -1,80 +1,115 @@
 package main
 
 import (
+	"crypto/tls"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net"
 	"os"
+	"os/signal"
 	"path"
 	"strings"
+	"syscall"
 	"time"
 
 	"code.dogecoin.org/gossip/dnet"
 	"code.dogecoin.org/governor"
 	"code.dogecoin.org/identity/internal/announce"
+	"code.dogecoin.org/identity/internal/auth"
+	"code.dogecoin.org/identity/internal/config"
+	"code.dogecoin.org/identity/internal/domains"
 	"code.dogecoin.org/identity/internal/handler"
+	"code.dogecoin.org/identity/internal/policy"
 	"code.dogecoin.org/identity/internal/spec"
 	"code.dogecoin.org/identity/internal/store"
 	"code.dogecoin.org/identity/internal/web"
 )
 
-const WebServerPort = 8099
 const DBFileName = "identity.db"
 
 var HandlerDefaultBind = spec.BindTo{Network: "unix", Address: "/tmp/dogenet.sock"} // const
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, found := commands[os.Args[1]]; found {
+			os.Exit(cmd(os.Args[2:]))
+		}
+	}
 	dir := "./storage"
-	webdir := "./web"
-	bind := dnet.Address{Host: net.IPv4zero, Port: WebServerPort}
-	handlerBind := HandlerDefaultBind
+	configFile := ""
+	useTLS := false
+	var flags config.File // settings given as flags override the config file
 	stderr := log.New(os.Stderr, "", 0)
 	flag.Func("dir", "<path> - storage directory (default './storage')", func(arg string) error {
-		ent, err := os.Stat(arg)
-		if err != nil {
+		if err := checkDir(arg); err != nil {
 			stderr.Fatalf("--dir: %v", err)
 		}
-		if !ent.IsDir() {
-			stderr.Fatalf("--dir: not a directory: %v", arg)
-		}
 		dir = arg
 		return nil
 	})
+	flag.StringVar(&configFile, "config", "", "<path> - config file (default '<dir>/config.json' if present)")
 	flag.Func("handler", "Handler bind <ip>:<port> or /unix/path (use [<ip>]:<port> for IPv6)", func(arg string) error {
-		bind, err := parseBindTo(arg, "handler")
-		if err != nil {
-			return err
-		}
-		handlerBind = bind
+		flags.Handler = arg
 		return nil
 	})
 	flag.Func("web", "<path> - web directory (default './web')", func(arg string) error {
-		ent, err := os.Stat(arg)
-		if err != nil {
+		if err := checkDir(arg); err != nil {
 			stderr.Fatalf("--web: %v", err)
 		}
-		if !ent.IsDir() {
-			stderr.Fatalf("--web: not a directory: %v", arg)
-		}
-		webdir = arg
+		flags.Web = arg
 		return nil
 	})
-	flag.Func("bind", "Bind web <ip>:<port> (use [<ip>]:<port> for IPv6)", func(arg string) error {
-		addr, err := parseIPPort(arg, "bind", WebServerPort)
-		if err != nil {
-			return err
+	flag.Func("bind", "Bind web <ip>:<port> or /unix/path (use [<ip>]:<port> for IPv6; repeat to listen on several)", func(arg string) error {
+		flags.Bind = append(flags.Bind, arg)
+		return nil
+	})
+	flag.BoolVar(&useTLS, "tls", false, "Serve the web API over TLS on <ip>:<port> binds (self-signed certificate in the storage directory)")
+	flag.Func("cors", "Allow cross-origin web requests from <origin>,... (use * for any)", func(arg string) error {
+		for _, origin := range strings.Split(arg, ",") {
+			origin = strings.TrimSpace(origin)
+			if origin != "" {
+				flags.CORS = append(flags.CORS, origin)
+			}
 		}
-		bind = addr
 		return nil
 	})
+	flag.StringVar(&flags.Blocklist, "blocklist", "", "<path> - import a shared blocklist file at startup (see README)")
+	flag.StringVar(&flags.KeyFile, "key-file", "", "<path> - read the identity private key from a file instead of the KEY env-var")
 	flag.Parse()
+	if isFlagSet(flag.CommandLine, "tls") {
+		flags.TLS = &useTLS
+	}
 
-	gov := governor.New().CatchSignals().Restart(1 * time.Second)
+	// config file in the storage directory, overridden by flags
+	configRequired := configFile != ""
+	if configFile == "" {
+		configFile = path.Join(dir, config.FileName)
+	}
+	conf, err := loadConfig(configFile, configRequired, flags)
+	if err != nil {
+		stderr.Fatalf("%v", err)
+	}
+	webBinds, handlerBind, err := parseBinds(conf)
+	if err != nil {
+		stderr.Fatalf("%v", err)
+	}
+	webdir := "./web"
+	if conf.Web != "" {
+		if err := checkDir(conf.Web); err != nil {
+			stderr.Fatalf("web: %v", err)
+		}
+		webdir = conf.Web
+	}
+	settings := config.NewSettings() // reloaded on SIGHUP
+	settings.Apply(conf)
 
-	// get the private key from the KEY env-var
-	idenKey := keyFromEnv()
+	gov := governor.New().Restart(1 * time.Second)
+
+	// get the private key from the KEY env-var (or the configured source)
+	idenKey := loadKey(conf)
 	log.Printf("Identity PubKey is: %v", hex.EncodeToString(idenKey.Pub[:]))
 
 	storeFilename := path.Join(dir, DBFileName)
@@ -84,36 +119,225 @@ func main() {
 		os.Exit(1)
 	}
 
+	// local blocklist and allowlist
+	blocks := policy.New()
+	err = loadPolicy(db.WithCtx(gov.GlobalContext()), blocks, conf.Blocklist)
+	if err != nil {
+		log.Printf("Error loading blocklist: %v\n", err)
+		os.Exit(1)
+	}
+
+	// admin token for mutating web API requests
+	tokenFilename := path.Join(dir, auth.TokenFileName)
+	adminToken, created, err := auth.LoadOrCreateToken(tokenFilename)
+	if err != nil {
+		log.Printf("Error loading admin token: %v [%s]\n", err, tokenFilename)
+		os.Exit(1)
+	}
+	if created {
+		log.Printf("Generated admin token: %v", tokenFilename)
+	}
+
+	// TLS certificate for the web API
+	var tlsConfig *tls.Config
+	if conf.UseTLS() {
+		certFilename := path.Join(dir, web.CertFileName)
+		keyFilename := path.Join(dir, web.KeyFileName)
+		cert, err := web.LoadOrCreateCert(certFilename, keyFilename, webBinds)
+		if err != nil {
+			log.Printf("Error loading TLS certificate: %v [%s]\n", err, certFilename)
+			os.Exit(1)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
 	newIdentity := make(chan dnet.RawMessage, 10) // announce -> handler
 	announceChanges := make(chan any, 10)         // handler,web -> announce
+	outgoing := make(chan dnet.RawMessage, 10)    // web -> handler
+
+	status := spec.NewStatus(idenKey.Pub[:]) // handler,announce -> web
 
-	identSvc := handler.New(handlerBind, db, idenKey, newIdentity, announceChanges)
+	identSvc := handler.New(handlerBind, db, idenKey, newIdentity, outgoing, announceChanges, status, blocks, settings)
 	gov.Add("ident", identSvc)
-	gov.Add("announce", announce.New(idenKey, db, newIdentity, announceChanges))
-	gov.Add("web", web.New(bind, webdir, announceChanges, db))
+	gov.Add("node", handler.NewNodeClaims(handlerBind, db, idenKey, blocks))
+	gov.Add("announce", announce.New(idenKey, db, newIdentity, announceChanges, status, settings))
+	gov.Add("web", web.New(webBinds, tlsConfig, webdir, announceChanges, outgoing, db, status, idenKey, adminToken, settings, blocks, domains.NewVerifier(nil, nil)))
+
+	// SIGHUP reloads the config file (governor's CatchSignals would shut down)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	go func() {
+		for sig := range signals {
+			if sig != syscall.SIGHUP {
+				log.Println("")
+				log.Println("Shutdown requested via signal")
+				gov.Shutdown()
+				return
+			}
+			next, err := loadConfig(configFile, configRequired, flags)
+			if err != nil {
+				log.Printf("Config reload failed (settings unchanged): %v", err)
+				continue
+			}
+			for _, name := range config.RestartNeeded(conf, next) {
+				log.Printf("Config reload: %v changed (restart to apply)", name)
+			}
+			settings.Apply(next)
+			err = loadPolicy(db.WithCtx(gov.GlobalContext()), blocks, next.Blocklist)
+			if err != nil {
+				log.Printf("Config reload: cannot load blocklist: %v", err)
+			}
+			log.Printf("Config reloaded: %v", configFile)
+		}
+	}()
 
 	gov.Start()
 	gov.WaitForShutdown()
 }
 
-func keyFromEnv() dnet.KeyPair {
-	// get the private key from the KEY env-var
-	idenHex := os.Getenv("KEY")
-	os.Setenv("KEY", "") // don't leave the key in the environment
+// loadConfig loads the config file, applies flags and validates the result.
+func loadConfig(filename string, required bool, flags config.File) (config.File, error) {
+	if err := flags.Validate(); err != nil {
+		return config.File{}, fmt.Errorf("bad flag: %v", err)
+	}
+	conf, err := config.Load(filename, required)
+	if err != nil {
+		return config.File{}, err
+	}
+	conf = conf.Override(flags)
+	if err := conf.Validate(); err != nil {
+		return config.File{}, fmt.Errorf("%v: %v", filename, err)
+	}
+	return conf, nil
+}
+
+// parseBinds parses the web binds and the handler bind.
+func parseBinds(conf config.File) (webBinds []spec.BindTo, handlerBind spec.BindTo, err error) {
+	port := uint16(config.DefaultPort)
+	if conf.Port != 0 {
+		port = uint16(conf.Port)
+	}
+	for _, arg := range conf.Bind {
+		bind, err := parseBindTo(arg, "bind", port, true)
+		if err != nil {
+			return nil, spec.BindTo{}, err
+		}
+		webBinds = append(webBinds, bind)
+	}
+	if len(webBinds) == 0 {
+		webBinds = []spec.BindTo{{Network: "tcp", Address: dnet.Address{Host: net.IPv4zero, Port: port}.String()}}
+	}
+	handlerBind = HandlerDefaultBind
+	if conf.Handler != "" {
+		handlerBind, err = parseBindTo(conf.Handler, "handler", 0, false)
+		if err != nil {
+			return nil, spec.BindTo{}, err
+		}
+	}
+	return webBinds, handlerBind, nil
+}
+
+func checkDir(arg string) error {
+	ent, err := os.Stat(arg)
+	if err != nil {
+		return err
+	}
+	if !ent.IsDir() {
+		return fmt.Errorf("not a directory: %v", arg)
+	}
+	return nil
+}
+
+// loadPolicy imports the blocklist file (replacing the rules imported from
+// the previous one, if any) then loads all rules.
+func loadPolicy(db spec.StoreCtx, blocks *policy.Policy, blocklist string) error {
+	var rules []spec.PolicyRule
+	if blocklist != "" {
+		file, err := os.Open(blocklist)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		rules, err = policy.ParseBlocklist(file, path.Base(blocklist))
+		if err != nil {
+			return err
+		}
+	}
+	err := db.ImportPolicyRules(policy.SourceConfig, rules)
+	if err != nil {
+		return err
+	}
+	if blocklist != "" {
+		log.Printf("Imported %v rules from blocklist: %v", len(rules), blocklist)
+	}
+	rules, err = db.ListPolicyRules()
+	if err != nil {
+		return err
+	}
+	blocks.Load(rules)
+	return nil
+}
+
+// loadKey gets the identity private key from the configured source.
+func loadKey(conf config.File) dnet.KeyPair {
+	if conf.KeyFile != "" {
+		return keyFromFile(conf.KeyFile)
+	}
+	name := conf.KeyEnv
+	if name == "" {
+		name = config.DefaultKeyEnv
+	}
+	return keyFromEnv(name)
+}
+
+func keyFromEnv(name string) dnet.KeyPair {
+	// get the private key from the env-var
+	idenHex := os.Getenv(name)
+	os.Setenv(name, "") // don't leave the key in the environment
 	if idenHex == "" {
-		log.Printf("Missing KEY env-var: identity private key (32 bytes)")
+		log.Printf("Missing %v env-var: identity private key (32 bytes)", name)
 		os.Exit(3)
 	}
-	idenKeyB, err := hex.DecodeString(idenHex)
+	key, err := parseKey(idenHex)
 	if err != nil {
-		log.Printf("Invalid KEY hex in env-var: %v", err)
+		log.Printf("Invalid %v hex in env-var: %v", name, err)
 		os.Exit(3)
 	}
-	if len(idenKeyB) != 32 {
-		log.Printf("Invalid KEY hex in env-var: must be 32 bytes")
+	return key
+}
+
+func keyFromFile(filename string) dnet.KeyPair {
+	ent, err := os.Stat(filename)
+	if err != nil {
+		log.Printf("Cannot read key file: %v", err)
+		os.Exit(3)
+	}
+	if ent.Mode().Perm()&0o077 != 0 {
+		log.Printf("Key file is readable by other users: %v (use chmod 600)", filename)
+		os.Exit(3)
+	}
+	idenHex, err := os.ReadFile(filename)
+	if err != nil {
+		log.Printf("Cannot read key file: %v", err)
 		os.Exit(3)
 	}
-	return dnet.KeyPairFromPrivKey((*[32]byte)(idenKeyB))
+	key, err := parseKey(strings.TrimSpace(string(idenHex)))
+	if err != nil {
+		log.Printf("Invalid key hex in key file: %v [%v]", err, filename)
+		os.Exit(3)
+	}
+	return key
+}
+
+func parseKey(idenHex string) (dnet.KeyPair, error) {
+	idenKeyB, err := hex.DecodeString(idenHex)
+	if err != nil {
+		return dnet.KeyPair{}, err
+	}
+	if len(idenKeyB) != 32 {
+		return dnet.KeyPair{}, errors.New("must be 32 bytes")
+	}
+	return dnet.KeyPairFromPrivKey((*[32]byte)(idenKeyB)), nil
 }
 
 // Parse an IPv4 or IPv6 address with optional port.
@@ -139,13 +363,29 @@ func parseIPPort(arg string, name string, defaultPort uint16) (dnet.Address, err
 	return res, nil
 }
 
-func parseBindTo(arg string, name string) (spec.BindTo, error) {
+// Parse a unix socket path or <ip>:<port> to connect or listen on.
+func parseBindTo(arg string, name string, defaultPort uint16, listen bool) (spec.BindTo, error) {
 	if strings.HasPrefix(arg, "/") {
 		// unix socket path.
 		ent, err := os.Stat(arg)
+		if listen && errors.Is(err, os.ErrNotExist) {
+			// will be created by Listen; the directory must exist.
+			dir, err := os.Stat(path.Dir(arg))
+			if err != nil {
+				return spec.BindTo{}, fmt.Errorf("bad --%v: %v", name, err)
+			}
+			if !dir.IsDir() {
+				return spec.BindTo{}, fmt.Errorf("bad --%v: not a directory: %v", name, path.Dir(arg))
+			}
+			return spec.BindTo{Network: "unix", Address: arg}, nil
+		}
 		if err != nil {
 			return spec.BindTo{}, fmt.Errorf("bad --%v: %v", name, err)
 		}
+		if listen && ent.Mode()&os.ModeSocket == 0 {
+			// don't replace a file that isn't a (stale) socket.
+			return spec.BindTo{}, fmt.Errorf("bad --%v: path exists and is not a socket: %v", name, arg)
+		}
 		if !ent.IsDir() {
 			// exists, not a directory.
 			return spec.BindTo{Network: "unix", Address: arg}, nil
@@ -153,7 +393,7 @@ func parseBindTo(arg string, name string) (spec.BindTo, error) {
 			return spec.BindTo{}, fmt.Errorf("bad --%v: path is a directory: %v", name, arg)
 		}
 	} else {
-		addr, err := parseIPPort(arg, name, 0)
+		addr, err := parseIPPort(arg, name, defaultPort)
 		if err != nil {
 			return spec.BindTo{}, fmt.Errorf("bad --%v: %v", name, err)
 		}